@@ -1,7 +1,7 @@
 #!/bin/bash
 set -euxo pipefail
 
-# Build the pair of zip files to upload to lambda
+# Build the zip files to upload to lambda
 
 mkdir -p build
 DIR=$(mktemp -d "build/build-$(git rev-parse --short HEAD)-XXXXXX")
@@ -31,4 +31,16 @@ zip checker.zip bootstrap ./*.pem
 popd
 cp "$DIR/checker/checker.zip" build/checker.zip
 
-echo "built: build/churner.zip build/checker.zip"
+
+# Sweeper binary and certs
+mkdir -p "$DIR/sweeper"
+go build -o "$DIR/sweeper/bootstrap" lambda/sweeper/sweeper.go
+cp checker/testdata/*.pem "$DIR/sweeper/"
+
+# zip
+pushd "$DIR/sweeper"
+zip sweeper.zip bootstrap ./*.pem
+popd
+cp "$DIR/sweeper/sweeper.zip" build/sweeper.zip
+
+echo "built: build/churner.zip build/checker.zip build/sweeper.zip"
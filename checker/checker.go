@@ -1,13 +1,17 @@
 package checker
 
 import (
+	"bytes"
 	"context"
 	"crypto"
 	"crypto/x509"
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"math/big"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -18,20 +22,53 @@ import (
 
 	"github.com/letsencrypt/crl-monitor/checker/earlyremoval"
 	"github.com/letsencrypt/crl-monitor/checker/expiry"
+	"github.com/letsencrypt/crl-monitor/checker/mutation"
+	"github.com/letsencrypt/crl-monitor/checker/readded"
 	"github.com/letsencrypt/crl-monitor/cmd"
 	"github.com/letsencrypt/crl-monitor/db"
+	"github.com/letsencrypt/crl-monitor/logging"
+	"github.com/letsencrypt/crl-monitor/metrics"
+	"github.com/letsencrypt/crl-monitor/notify"
 	"github.com/letsencrypt/crl-monitor/storage"
 )
 
 const (
-	BoulderBaseURL    cmd.EnvVar = "BOULDER_BASE_URL"
-	BoulderMaxFetch   cmd.EnvVar = "BOULDER_MAX_FETCH"
-	DynamoEndpointEnv cmd.EnvVar = "DYNAMO_ENDPOINT"
-	DynamoTableEnv    cmd.EnvVar = "DYNAMO_TABLE"
-	CRLAgeLimit       cmd.EnvVar = "CRL_AGE_LIMIT"
-	IssuerPaths       cmd.EnvVar = "ISSUER_PATHS"
+	BoulderBaseURL          cmd.EnvVar = "BOULDER_BASE_URL"
+	BoulderMaxFetch         cmd.EnvVar = "BOULDER_MAX_FETCH"
+	BoulderFetchConcurrency cmd.EnvVar = "BOULDER_FETCH_CONCURRENCY"
+	BoulderFetchTimeout     cmd.EnvVar = "BOULDER_FETCH_TIMEOUT"
+	BoulderFetchCacheSize   cmd.EnvVar = "BOULDER_FETCH_CACHE_SIZE"
+	DynamoEndpointEnv       cmd.EnvVar = "DYNAMO_ENDPOINT"
+	DynamoTableEnv          cmd.EnvVar = "DYNAMO_TABLE"
+	DynamoLatencyTableEnv   cmd.EnvVar = "DYNAMO_LATENCY_TABLE"
+	DynamoExpiryTableEnv    cmd.EnvVar = "DYNAMO_EXPIRY_TABLE"
+	CRLAgeLimit             cmd.EnvVar = "CRL_AGE_LIMIT"
+	IssuerPaths             cmd.EnvVar = "ISSUER_PATHS"
+	ReaddWindow             cmd.EnvVar = "READD_WINDOW"
+	RevocationTimeTolerance cmd.EnvVar = "REVOCATION_TIME_TOLERANCE"
 )
 
+// defaultFetchConcurrency is how many certificates are fetched from Boulder at
+// once when BOULDER_FETCH_CONCURRENCY is unset.
+const defaultFetchConcurrency = 10
+
+// defaultFetchTimeout bounds the time spent fetching certificates for the early
+// removal check when BOULDER_FETCH_TIMEOUT is unset. It leaves the rest of a
+// Lambda invocation for the other checks.
+const defaultFetchTimeout = 5 * time.Minute
+
+// defaultFetchCacheSize is how many certificates' NotAfter are kept in memory
+// when BOULDER_FETCH_CACHE_SIZE is unset. Each entry is around 100 bytes.
+const defaultFetchCacheSize = 100_000
+
+// defaultReaddWindow is how many versions before the current one are searched
+// for removed serials when READD_WINDOW is unset.
+const defaultReaddWindow = 4
+
+// defaultRevocationTimeTolerance is how far the revocation time on a CRL may be
+// from the time the churner recorded when REVOCATION_TIME_TOLERANCE is unset.
+const defaultRevocationTimeTolerance = 5 * time.Minute
+
 func nameID(issuer *x509.Certificate) string {
 	h := crypto.SHA1.New()
 	h.Write(issuer.RawSubject)
@@ -39,19 +76,75 @@ func nameID(issuer *x509.Certificate) string {
 	return fmt.Sprintf("%d", big.NewInt(0).SetBytes(s[:7]))
 }
 
-func New(database *db.Database, storage *storage.Storage, fetcher earlyremoval.Fetcher, maxFetch int, ageLimit time.Duration, issuers []*x509.Certificate) *Checker {
-	issuerMap := make(map[string]*x509.Certificate, len(issuers))
-	for _, issuer := range issuers {
+// Storage is a source of versioned CRL shards. It is fulfilled by
+// *storage.Storage, which reads from S3, and *storage.Filesystem.
+type Storage interface {
+	Fetch(ctx context.Context, key storage.Key) ([]byte, string, error)
+	Previous(ctx context.Context, key storage.Key) (string, error)
+	Uploaded(ctx context.Context, key storage.Key) (time.Time, error)
+	List(ctx context.Context, bucket, prefix string) ([]storage.Object, error)
+}
+
+// Config holds everything a Checker needs. Apart from Storage and, for Check,
+// DB, the zero value of each field skips or defaults what it configures.
+type Config struct {
+	// DB holds the certificates revoked by the churner, which Check deletes
+	// once they're seen on a CRL. Replay and CheckShards don't use it.
+	DB      *db.Database
+	Storage Storage
+	// Fetcher looks up the NotAfter of serials removed from a CRL. If nil,
+	// early removal isn't checked.
+	Fetcher earlyremoval.Fetcher
+	// MaxFetch, if positive, is how many removed serials are sampled to look
+	// up. FetchConcurrency is how many are looked up at once, and
+	// FetchTimeout, if positive, bounds how long that takes.
+	MaxFetch         int
+	FetchConcurrency int
+	FetchTimeout     time.Duration
+	// AgeLimit is how old a CRL's thisUpdate may be before it fails linting.
+	AgeLimit time.Duration
+	Issuers  []*x509.Certificate
+	// ReaddWindow is how many versions before the current one are searched
+	// for serials that were removed. It must be at least 2 to detect anything.
+	ReaddWindow int
+	// RevocationTimeTolerance is how far a churned cert's revocation time on
+	// the CRL may be from the time the churner recorded.
+	RevocationTimeTolerance time.Duration
+	// Metrics and Notifier default to discarding everything.
+	Metrics  metrics.Sink
+	Notifier notify.Notifier
+}
+
+func New(cfg Config) *Checker {
+	issuerMap := make(map[string]*x509.Certificate, len(cfg.Issuers))
+	for _, issuer := range cfg.Issuers {
 		issuerMap[nameID(issuer)] = issuer
 	}
 
+	sink := cfg.Metrics
+	if sink == nil {
+		sink = metrics.Discard
+	}
+	notifier := cfg.Notifier
+	if notifier == nil {
+		notifier = notify.Discard
+	}
+
 	return &Checker{
-		db:       database,
-		storage:  storage,
-		fetcher:  fetcher,
-		maxFetch: maxFetch,
-		ageLimit: ageLimit,
+		db:       cfg.DB,
+		storage:  cfg.Storage,
+		fetcher:  cfg.Fetcher,
+		maxFetch: cfg.MaxFetch,
+		ageLimit: cfg.AgeLimit,
 		issuers:  issuerMap,
+
+		fetchConcurrency: cfg.FetchConcurrency,
+		fetchTimeout:     cfg.FetchTimeout,
+
+		readdWindow:             cfg.ReaddWindow,
+		revocationTimeTolerance: cfg.RevocationTimeTolerance,
+		metrics:                 sink,
+		notifier:                notifier,
 	}
 }
 
@@ -72,14 +165,64 @@ func NewFromEnv(ctx context.Context) (*Checker, error) {
 		}
 	}
 
+	fetchConcurrency := defaultFetchConcurrency
+	fetchConcurrencyString, hasFetchConcurrency := BoulderFetchConcurrency.LookupEnv()
+	if hasFetchConcurrency {
+		var err error
+		fetchConcurrency, err = strconv.Atoi(fetchConcurrencyString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s as int (%s): %v", BoulderFetchConcurrency, fetchConcurrencyString, err)
+		}
+	}
+
+	fetchTimeout := defaultFetchTimeout
+	fetchTimeoutString, hasFetchTimeout := BoulderFetchTimeout.LookupEnv()
+	if hasFetchTimeout {
+		var err error
+		fetchTimeout, err = time.ParseDuration(fetchTimeoutString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", BoulderFetchTimeout, err)
+		}
+	}
+
+	fetchCacheSize := defaultFetchCacheSize
+	fetchCacheSizeString, hasFetchCacheSize := BoulderFetchCacheSize.LookupEnv()
+	if hasFetchCacheSize {
+		var err error
+		fetchCacheSize, err = strconv.Atoi(fetchCacheSizeString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s as int (%s): %v", BoulderFetchCacheSize, fetchCacheSizeString, err)
+		}
+	}
+
+	readdWindow := defaultReaddWindow
+	readdWindowString, hasReaddWindow := ReaddWindow.LookupEnv()
+	if hasReaddWindow {
+		var err error
+		readdWindow, err = strconv.Atoi(readdWindowString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s as int (%s): %v", ReaddWindow, readdWindowString, err)
+		}
+	}
+
 	database, err := db.New(ctx, dynamoTable, dynamoEndpoint)
 	if err != nil {
 		return nil, fmt.Errorf("database setup: %w", err)
 	}
+	// The latency table is optional, and latencies aren't recorded without it
+	database.LatencyTable, _ = DynamoLatencyTableEnv.LookupEnv()
 
 	baf := expiry.BoulderAPIFetcher{
 		BaseURL: boulderBaseURL,
 	}
+	// The expiry table is optional, and without it lookups are only cached
+	// in memory, for as long as the Lambda container lives
+	var expiryStore expiry.Store
+	if expiryTable, ok := DynamoExpiryTableEnv.LookupEnv(); ok {
+		database.ExpiryTable = expiryTable
+		expiryStore = database
+	}
+	fetcher := expiry.NewCachingFetcher(&baf, fetchCacheSize, expiryStore)
 
 	ageLimitDuration := 24 * time.Hour
 	if hasAgeLimit {
@@ -89,28 +232,77 @@ func NewFromEnv(ctx context.Context) (*Checker, error) {
 		}
 	}
 
+	revocationTimeTolerance := defaultRevocationTimeTolerance
+	revocationTimeToleranceString, hasRevocationTimeTolerance := RevocationTimeTolerance.LookupEnv()
+	if hasRevocationTimeTolerance {
+		revocationTimeTolerance, err = time.ParseDuration(revocationTimeToleranceString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", RevocationTimeTolerance, err)
+		}
+	}
+
+	issuers := loadIssuers(issuerPaths)
+
+	notifier, err := notify.FromEnv(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("notifier setup: %w", err)
+	}
+
+	return New(Config{
+		DB:                      database,
+		Storage:                 storage.New(ctx),
+		Fetcher:                 fetcher,
+		MaxFetch:                maxFetch,
+		FetchConcurrency:        fetchConcurrency,
+		FetchTimeout:            fetchTimeout,
+		AgeLimit:                ageLimitDuration,
+		Issuers:                 issuers,
+		ReaddWindow:             readdWindow,
+		RevocationTimeTolerance: revocationTimeTolerance,
+		Metrics:                 metrics.NewEMF(os.Stdout, metrics.Namespace),
+		Notifier:                notifier,
+	}), nil
+}
+
+// loadIssuers loads a colon (:) separated list of PEM-formatted issuer
+// certificates, exiting if any can't be loaded.
+func loadIssuers(issuerPaths string) []*x509.Certificate {
 	var issuers []*x509.Certificate
 	for _, issuer := range strings.Split(issuerPaths, ":") {
 		issuer, err := core.LoadCert(issuer)
 		if err != nil {
 			log.Fatalf("error loading issuer certificate: %v", err)
 		}
-		log.Printf("Loaded issuer CN=%s", issuer.Subject.CommonName)
+		slog.Info("loaded issuer", logging.Issuer, nameID(issuer), "commonName", issuer.Subject.CommonName)
 		issuers = append(issuers, issuer)
 	}
-
-	return New(database, storage.New(ctx), &baf, maxFetch, ageLimitDuration, issuers), nil
+	return issuers
 }
 
 // The Checker handles fetching and linting CRLs.
 // Use New to obtain one.
 type Checker struct {
 	db       *db.Database
-	storage  *storage.Storage
+	storage  Storage
 	fetcher  earlyremoval.Fetcher
 	maxFetch int
 	ageLimit time.Duration
 	issuers  map[string]*x509.Certificate
+
+	// fetchConcurrency is how many certificates the early removal check
+	// fetches at once, and fetchTimeout, if positive, bounds how long it
+	// spends fetching them.
+	fetchConcurrency int
+	fetchTimeout     time.Duration
+	// readdWindow is how many versions before the current one are searched
+	// for serials that were removed. It must be at least 2 to detect anything.
+	readdWindow int
+	// revocationTimeTolerance is how far a churned cert's revocation time on
+	// the CRL may be from the time the churner recorded.
+	revocationTimeTolerance time.Duration
+	metrics                 metrics.Sink
+	// notifier is sent an alert for each violation found by Check.
+	notifier notify.Notifier
 }
 
 // storageKey is nearly analogous to storage.Key, except that the Version field
@@ -121,9 +313,9 @@ type storageKey struct {
 	Version string
 }
 
-// crlSummary is a subset of fields from *x509.RevocationList
+// CRLSummary is a subset of fields from *x509.RevocationList
 // useful for logging, plus the number of entries and some metadata.
-type crlSummary struct {
+type CRLSummary struct {
 	Number     *big.Int
 	NumEntries int
 	ThisUpdate time.Time
@@ -132,10 +324,10 @@ type crlSummary struct {
 	StorageKey storageKey
 }
 
-func summary(crl *x509.RevocationList, key storage.Key) crlSummary {
+func summary(crl *x509.RevocationList, key storage.Key) CRLSummary {
 	// If getIDP fails, we will just log ""
 	idp, _ := getIDP(crl)
-	return crlSummary{
+	return CRLSummary{
 		ThisUpdate: crl.ThisUpdate,
 		NextUpdate: crl.NextUpdate,
 		Number:     crl.Number,
@@ -149,12 +341,32 @@ func summary(crl *x509.RevocationList, key storage.Key) crlSummary {
 	}
 }
 
-type crlsSummary struct {
-	Old, New crlSummary
+// LogValue logs a CRLSummary as a group with the same attribute keys as the
+// rest of the checker's logs.
+func (s CRLSummary) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String(logging.Bucket, s.StorageKey.Bucket),
+		slog.String(logging.Object, s.StorageKey.Object),
+		slog.String(logging.Version, s.StorageKey.Version),
+		slog.String(logging.CRLNumber, s.Number.String()),
+		slog.String(logging.IDP, s.URL),
+		slog.Int("numEntries", s.NumEntries),
+		slog.Time("thisUpdate", s.ThisUpdate),
+		slog.Time("nextUpdate", s.NextUpdate),
+	)
 }
 
-func logSummary(old *x509.RevocationList, oldStorageKey storage.Key, new *x509.RevocationList, newStorageKey storage.Key) crlsSummary {
-	return crlsSummary{
+// CRLsSummary is a pair of CRLSummary for the versions a check compared.
+type CRLsSummary struct {
+	Old, New CRLSummary
+}
+
+func (s CRLsSummary) LogValue() slog.Value {
+	return slog.GroupValue(slog.Any("old", s.Old), slog.Any("new", s.New))
+}
+
+func logSummary(old *x509.RevocationList, oldStorageKey storage.Key, new *x509.RevocationList, newStorageKey storage.Key) CRLsSummary {
+	return CRLsSummary{
 		Old: summary(old, oldStorageKey),
 		New: summary(new, newStorageKey),
 	}
@@ -162,38 +374,64 @@ func logSummary(old *x509.RevocationList, oldStorageKey storage.Key, new *x509.R
 
 // Check fetches a CRL and its previous version.  It runs lints on the CRL, checks for early removal, and removes any
 // certificates we're waiting for out of the database.
+//
+// Errors are one of the types in errors.go. Use IsViolation to tell a problem
+// with the CRL from a transient failure. Either way, it's logged along with
+// its severity, and counted in the Violations or TransientErrors metric.
 func (c *Checker) Check(ctx context.Context, bucket, object string, startingVersion *string) error {
+	prefix, _, _ := strings.Cut(object, "/")
+	ctx, logger := logging.With(ctx, logging.Bucket, bucket, logging.Object, object, logging.Issuer, prefix)
+
+	err := c.check(ctx, bucket, object, startingVersion)
+	if err != nil {
+		severity, metric := "transient", "TransientErrors"
+		if IsViolation(err) {
+			severity, metric = "violation", "Violations"
+		}
+		logger.Error("error checking CRL", "severity", severity, "errorType", fmt.Sprintf("%T", err), "error", err, "details", errorDetails(err))
+		c.emit(object, metrics.Metric{Name: metric, Value: 1, Unit: metrics.Count})
+
+		if IsViolation(err) {
+			notifyErr := c.notifier.Notify(ctx, alertFor(bucket, object, startingVersion, err))
+			if notifyErr != nil {
+				// The violation is still returned, so it's not lost
+				logger.Error("error sending alert", "error", notifyErr)
+			}
+		}
+	}
+	return err
+}
+
+func (c *Checker) check(ctx context.Context, bucket, object string, startingVersion *string) error {
+	logger := logging.FromContext(ctx)
+
 	// Read the current CRL shard
-	crlDER, version, err := c.storage.Fetch(ctx, storage.Key{
+	startingKey := storage.Key{
 		Bucket:  bucket,
 		Object:  object,
 		Version: startingVersion,
-	})
-	if err != nil {
-		return err
 	}
-
-	crl, err := x509.ParseRevocationList(crlDER)
+	crlDER, version, err := c.storage.Fetch(ctx, startingKey)
 	if err != nil {
-		return fmt.Errorf("parsing current crl: %v", err)
+		return &StorageError{Key: startingKey, Err: err}
 	}
-	log.Printf("loaded CRL number %d (len %d) from %s version %s", crl.Number, len(crl.RevokedCertificateEntries), object, version)
 
-	issuer, err := c.issuerForObject(object)
-	if err != nil {
-		return err
-	}
+	ctx, logger = logging.With(ctx, logging.Version, version)
 
-	err = checker.Validate(crl, issuer, c.ageLimit)
+	crl, err := x509.ParseRevocationList(crlDER)
 	if err != nil {
-		return fmt.Errorf("crl failed linting: %v", err)
+		return &LintError{Object: object, Err: fmt.Errorf("parsing current crl: %v", err)}
 	}
-	log.Printf("crl %d successfully linted", crl.Number)
+	// If getIDP fails, linting will catch it
+	idp, _ := getIDP(crl)
+	ctx, logger = logging.With(ctx, logging.CRLNumber, crl.Number.String(), logging.IDP, idp)
+	logger.Info("loaded crl", "numEntries", len(crl.RevokedCertificateEntries))
 
-	_, err = getIDP(crl)
+	err = c.lint(ctx, object, crl)
 	if err != nil {
 		return err
 	}
+	c.emit(object, metrics.Metric{Name: "NumEntries", Value: float64(len(crl.RevokedCertificateEntries)), Unit: metrics.Count})
 
 	curKey := storage.Key{
 		Bucket:  bucket,
@@ -203,7 +441,7 @@ func (c *Checker) Check(ctx context.Context, bucket, object string, startingVers
 	// And the previous:
 	prevVersion, err := c.storage.Previous(ctx, curKey)
 	if err != nil {
-		return err
+		return &StorageError{Key: curKey, Err: err}
 	}
 
 	prevKey := curKey
@@ -211,22 +449,154 @@ func (c *Checker) Check(ctx context.Context, bucket, object string, startingVers
 
 	prevDER, _, err := c.storage.Fetch(ctx, prevKey)
 	if err != nil {
-		return err
+		return &StorageError{Key: prevKey, Err: err}
 	}
 
 	prev, err := x509.ParseRevocationList(prevDER)
 	if err != nil {
-		return fmt.Errorf("parsing previous crl: %v", err)
+		return &LintError{Object: object, Err: fmt.Errorf("parsing previous crl version %s: %v", prevVersion, err)}
+	}
+	logger.Info("loaded previous crl", "previousVersion", prevVersion, "previousCRLNumber", prev.Number.String(), "previousNumEntries", len(prev.RevokedCertificateEntries))
+
+	added, removed := diffCounts(prev, crl)
+	sampleSize := removed
+	if c.maxFetch > 0 && sampleSize > c.maxFetch {
+		sampleSize = c.maxFetch
+	}
+	c.emit(object,
+		metrics.Metric{Name: "SerialsAdded", Value: float64(added), Unit: metrics.Count},
+		metrics.Metric{Name: "SerialsRemoved", Value: float64(removed), Unit: metrics.Count},
+		metrics.Metric{Name: "EarlyRemovalSampleSize", Value: float64(sampleSize), Unit: metrics.Count},
+	)
+
+	// Which churned certs are on the CRL doesn't depend on how it compares
+	// with the previous version, so they're looked for either way
+	compareErr := c.compare(ctx, prev, prevKey, crl, curKey, func() ([]readded.Version, error) {
+		return c.earlierVersions(ctx, prevKey)
+	})
+	seenErr := c.lookForSeenCerts(ctx, curKey, crl)
+	return errors.Join(compareErr, seenErr)
+}
+
+// compare runs the checks between prev and crl, consecutive versions of a
+// shard, which are shared by check and Replay: their order, early removal if
+// there's a fetcher, mutations, and re-added serials. The versions before prev
+// for the re-added check are only loaded, with earlier, if it's enabled.
+//
+// Versions out of order can't be compared any further, so that's returned
+// alone. Otherwise, every problem found is returned joined together.
+func (c *Checker) compare(ctx context.Context, prev *x509.RevocationList, prevKey storage.Key, crl *x509.RevocationList, curKey storage.Key, earlier func() ([]readded.Version, error)) error {
+	// Bit-for-bit identical uploads are allowed, and have nothing to compare
+	if !bytes.Equal(prev.Raw, crl.Raw) {
+		err := checkOrder(prev, crl)
+		if err != nil {
+			return &OrderError{CRLs: logSummary(prev, prevKey, crl, curKey), Err: err}
+		}
+	}
+
+	var errs []error
+	if c.fetcher != nil {
+		errs = append(errs, c.checkEarlyRemoval(ctx, prev, prevKey, crl, curKey))
+	}
+	errs = append(errs, checkMutations(ctx, prev, prevKey, crl, curKey))
+	if c.readdWindow >= 2 {
+		versions, err := earlier()
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		versions = append(versions, readded.Version{ID: prevKey.VersionString(), CRL: prev}, readded.Version{ID: curKey.VersionString(), CRL: crl})
+		errs = append(errs, checkReadded(ctx, versions, logSummary(prev, prevKey, crl, curKey)))
+	}
+	return errors.Join(errs...)
+}
+
+// checkOrder errors unless crl can be compared with prev, which means it has
+// the same issuer, and both a higher CRL number and a ThisUpdate no earlier
+// than prev.
+func checkOrder(prev, crl *x509.RevocationList) error {
+	_, err := checker.Diff(prev, crl)
+	if err != nil {
+		return fmt.Errorf("crl number %d with thisUpdate %s doesn't follow previous number %d with thisUpdate %s: %w",
+			crl.Number, crl.ThisUpdate.Format(time.RFC3339), prev.Number, prev.ThisUpdate.Format(time.RFC3339), err)
+	}
+	return nil
+}
+
+// emit sends metrics about a shard, with its issuer's prefix as the dimension.
+func (c *Checker) emit(object string, m ...metrics.Metric) {
+	prefix, _, _ := strings.Cut(object, "/")
+	c.metrics.Emit(map[string]string{"Issuer": prefix}, m...)
+}
+
+// diffCounts returns how many serials were added and removed between prev
+// and crl.
+func diffCounts(prev, crl *x509.RevocationList) (int, int) {
+	prevSerials := make(map[string]bool, len(prev.RevokedCertificateEntries))
+	for _, entry := range prev.RevokedCertificateEntries {
+		prevSerials[entry.SerialNumber.String()] = true
 	}
-	log.Printf("loaded previous CRL number %d (len %d) from version %s", prev.Number, len(prev.RevokedCertificateEntries), prevVersion)
 
-	context := logSummary(prev, prevKey, crl, curKey)
+	added := 0
+	for _, entry := range crl.RevokedCertificateEntries {
+		if prevSerials[entry.SerialNumber.String()] {
+			delete(prevSerials, entry.SerialNumber.String())
+		} else {
+			added++
+		}
+	}
+	return added, len(prevSerials)
+}
 
-	earlyRemoved, err := earlyremoval.Check(ctx, c.fetcher, c.maxFetch, prev, crl)
+// lint validates a CRL against the issuer for its object path, and checks it
+// has a single IssuingDistributionPoint.
+func (c *Checker) lint(ctx context.Context, object string, crl *x509.RevocationList) error {
+	issuer, err := c.issuerForObject(object)
 	if err != nil {
-		return fmt.Errorf("checking for early removal: %v. context: %+v", err, context)
+		return &LintError{Object: object, Err: err}
 	}
 
+	err = checker.Validate(crl, issuer, c.ageLimit)
+	if err != nil {
+		return &LintError{Object: object, Err: err}
+	}
+	logging.FromContext(ctx).Info("crl successfully linted")
+
+	_, err = getIDP(crl)
+	if err != nil {
+		idps, _ := idp.GetIDPURIs(crl.Extensions)
+		return &IDPMismatchError{Object: object, IDPs: idps, Err: err}
+	}
+
+	err = checkReasonCodes(crl)
+	if err != nil {
+		return &LintError{Object: object, Err: err}
+	}
+	return nil
+}
+
+// checkEarlyRemoval errors if any serials removed between prev and crl
+// belong to certificates that hadn't expired by the time prev was published.
+// If not every certificate could be fetched within fetchTimeout, any early
+// removals found are still reported, joined with a LookupError.
+func (c *Checker) checkEarlyRemoval(ctx context.Context, prev *x509.RevocationList, prevKey storage.Key, crl *x509.RevocationList, curKey storage.Key) error {
+	fetchCtx := ctx
+	if c.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, c.fetchTimeout)
+		defer cancel()
+	}
+
+	crls := logSummary(prev, prevKey, crl, curKey)
+
+	earlyRemoved, err := earlyremoval.Check(fetchCtx, c.fetcher, c.maxFetch, c.fetchConcurrency, prev, crl)
+	var incomplete *earlyremoval.IncompleteError
+	if err != nil && !errors.As(err, &incomplete) {
+		// Failures to look up certificates are always an IncompleteError, so
+		// this is checker.Diff refusing to compare the CRLs
+		return &OrderError{CRLs: crls, Err: fmt.Errorf("checking for early removal: %w", err)}
+	}
+
+	var errs []error
 	if len(earlyRemoved) != 0 {
 		sample := earlyRemoved
 		if len(sample) > 50 {
@@ -234,32 +604,115 @@ func (c *Checker) Check(ctx context.Context, bucket, object string, startingVers
 		}
 
 		// Certificates removed early!  This is very bad.
-		return fmt.Errorf("early removal of %d certificates detected! First %d: %v. context: %+v", len(earlyRemoved), len(sample), sample, context)
+		errs = append(errs, &EarlyRemovalError{CRLs: crls, Count: len(earlyRemoved), Sample: sample})
+	}
+	if incomplete != nil {
+		c.emit(curKey.Object, metrics.Metric{Name: "EarlyRemovalUnchecked", Value: float64(incomplete.Sampled - incomplete.Fetched), Unit: metrics.Count})
+		logging.FromContext(ctx).Warn("early removal check incomplete", "sampled", incomplete.Sampled, "fetched", incomplete.Fetched, "crls", crls)
+		errs = append(errs, &LookupError{Err: fmt.Errorf("checking for early removal: %w", incomplete)})
+	}
+	return errors.Join(errs...)
+}
+
+// checkMutations errors if the revocation time or reason of any serial on both
+// prev and crl changed, other than an update of the reason to keyCompromise.
+func checkMutations(ctx context.Context, prev *x509.RevocationList, prevKey storage.Key, crl *x509.RevocationList, curKey storage.Key) error {
+	mutations := mutation.Check(prev, crl)
+	logging.FromContext(ctx).Info("checked for mutations", "mutations", len(mutations))
+	if len(mutations) != 0 {
+		sample := mutations
+		if len(sample) > 50 {
+			sample = sample[:50]
+		}
+		return &MutationError{CRLs: logSummary(prev, prevKey, crl, curKey), Count: len(mutations), Sample: sample}
 	}
+	return nil
+}
+
+// earlierVersions walks back through storage from prevKey, returning up to
+// the readdWindow-1 versions before it, oldest first, for checkReadded.
+func (c *Checker) earlierVersions(ctx context.Context, prevKey storage.Key) ([]readded.Version, error) {
+	var versions []readded.Version
+	key := prevKey
+	for len(versions) < c.readdWindow-1 {
+		version, err := c.storage.Previous(ctx, key)
+		if errors.Is(err, storage.ErrNoPreviousVersion) {
+			break
+		}
+		if err != nil {
+			return nil, &StorageError{Key: key, Err: err}
+		}
+		key.Version = &version
 
-	return c.lookForSeenCerts(ctx, crl)
+		der, _, err := c.storage.Fetch(ctx, key)
+		if err != nil {
+			return nil, &StorageError{Key: key, Err: err}
+		}
+		older, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, &LintError{Object: key.Object, Err: fmt.Errorf("parsing crl version %s: %v", version, err)}
+		}
+		versions = slices.Insert(versions, 0, readded.Version{ID: version, CRL: older})
+	}
+	return versions, nil
+}
+
+// checkReadded errors if any serials added in the newest of versions, which
+// are consecutive and oldest first, had been removed in one of them.
+func checkReadded(ctx context.Context, versions []readded.Version, crls CRLsSummary) error {
+	readds, err := readded.Check(versions)
+	logging.FromContext(ctx).Info("checked for re-added serials", "versions", len(versions), "readded", len(readds))
+	if err != nil {
+		// Versions out of order can't be compared
+		return &OrderError{CRLs: crls, Err: fmt.Errorf("checking for re-added serials: %v", err)}
+	}
+
+	if len(readds) != 0 {
+		sample := readds
+		if len(sample) > 50 {
+			sample = sample[:50]
+		}
+		return &ReaddedError{CRLs: crls, Count: len(readds), Sample: sample}
+	}
+	return nil
 }
 
 // lookForSeenCerts removes any certs in this CRL from the database, as they've now appeared in a CRL.
-// We expect the database to be much smaller than CRLs, so we load the entire database into memory.
-func (c *Checker) lookForSeenCerts(ctx context.Context, crl *x509.RevocationList) error {
-	unseenCerts, err := c.db.GetAllCerts(ctx)
+// Only the certs whose CRLDistributionPoint matches this CRL's IssuingDistributionPoint are loaded, so a
+// cert that shows up on the wrong shard stays in the database, and the churner reports it as missing.
+// The time each seen cert took to be published is logged and recorded.
+func (c *Checker) lookForSeenCerts(ctx context.Context, key storage.Key, crl *x509.RevocationList) error {
+	idp, err := getIDP(crl)
+	if err != nil {
+		return &IDPMismatchError{Object: key.Object, Err: err}
+	}
+
+	unseenCerts, err := c.db.GetCertsForIDP(ctx, idp)
 	if err != nil {
-		return fmt.Errorf("getting all certs from DB: %v", err)
+		return &LookupError{Err: fmt.Errorf("getting certs for %s from DB: %v", idp, err)}
 	}
+
 	var seenSerials [][]byte
 	var errs []error
+	var uploaded time.Time
 	for _, seen := range crl.RevokedCertificateEntries {
 		if metadata, ok := unseenCerts[db.NewCertKey(seen.SerialNumber).SerialString()]; ok {
-			idp, err := getIDP(crl)
+			// Only look up the upload time once there's a seen cert to use it
+			if uploaded.IsZero() {
+				uploaded, err = c.storage.Uploaded(ctx, key)
+				if err != nil {
+					return &StorageError{Key: key, Err: err}
+				}
+			}
+			c.recordLatency(ctx, key, crl, uploaded, metadata)
+
+			err = checkChurnedReason(seen, metadata)
 			if err != nil {
-				errs = append(errs, err)
-				continue
+				errs = append(errs, &ChurnedCertError{Serial: seen.SerialNumber, IDP: idp, Err: err})
 			}
-			if metadata.CRLDistributionPoint != "" && metadata.CRLDistributionPoint != idp {
-				errs = append(errs, fmt.Errorf("cert %x on CRL %q has non-matching CRLDistributionPoint %q",
-					seen.SerialNumber, idp, metadata.CRLDistributionPoint))
-				continue
+			err = c.checkRevocationTime(seen, metadata)
+			if err != nil {
+				errs = append(errs, &ChurnedCertError{Serial: seen.SerialNumber, IDP: idp, Err: err})
 			}
 			seenSerials = append(seenSerials, metadata.SerialNumber)
 		}
@@ -267,11 +720,53 @@ func (c *Checker) lookForSeenCerts(ctx context.Context, crl *x509.RevocationList
 
 	err = c.db.DeleteSerials(ctx, seenSerials)
 	if err != nil {
-		errs = append(errs, fmt.Errorf("deleting %d serials from DB: %v", len(seenSerials), err))
+		errs = append(errs, &LookupError{Err: fmt.Errorf("deleting %d serials from DB: %v", len(seenSerials), err)})
 	}
 	return errors.Join(errs...)
 }
 
+// recordLatency logs how long a churned cert took to be published, both to the
+// CRL's thisUpdate and to its upload, and adds it to the day's summary in the
+// database. Failing to record it isn't a problem with the CRL, so is only logged.
+func (c *Checker) recordLatency(ctx context.Context, key storage.Key, crl *x509.RevocationList, uploaded time.Time, metadata db.CertMetadata) {
+	thisUpdateLatency := crl.ThisUpdate.Sub(metadata.RevocationTime)
+	uploadLatency := uploaded.Sub(metadata.RevocationTime)
+	logger := logging.FromContext(ctx).With(logging.Serial, metadata.SerialString())
+	logger.Info("churned certificate published",
+		"variant", metadata.Variant,
+		"revocationMethod", metadata.RevocationMethod,
+		"revocationTime", metadata.RevocationTime,
+		"thisUpdate", crl.ThisUpdate,
+		"uploaded", uploaded,
+		"thisUpdateLatencySeconds", thisUpdateLatency.Seconds(),
+		"uploadLatencySeconds", uploadLatency.Seconds(),
+	)
+	c.emit(key.Object,
+		metrics.Metric{Name: "RevocationToCRL", Value: thisUpdateLatency.Seconds(), Unit: metrics.Seconds},
+		metrics.Metric{Name: "RevocationToUpload", Value: uploadLatency.Seconds(), Unit: metrics.Seconds},
+	)
+
+	err := c.db.RecordPublicationLatency(ctx, uploaded, thisUpdateLatency, uploadLatency)
+	if err != nil {
+		logger.Error("error recording publication latency", "error", err)
+	}
+}
+
+// checkRevocationTime errors if a churned cert's revocation time on the CRL
+// is too far from the time the churner recorded, or before it was issued.
+func (c *Checker) checkRevocationTime(seen x509.RevocationListEntry, metadata db.CertMetadata) error {
+	if !metadata.NotBefore.IsZero() && seen.RevocationTime.Before(metadata.NotBefore) {
+		return fmt.Errorf("revocation time %s is before issuance time %s", seen.RevocationTime, metadata.NotBefore)
+	}
+
+	difference := seen.RevocationTime.Sub(metadata.RevocationTime).Abs()
+	if difference > c.revocationTimeTolerance {
+		return fmt.Errorf("revocation time %s differs from recorded revocation time %s by %s, more than %s",
+			seen.RevocationTime, metadata.RevocationTime, difference, c.revocationTimeTolerance)
+	}
+	return nil
+}
+
 // issuerForObject takes an s3 object path, extracts the issuer prefix, and returns the right x509.Certificate
 func (c *Checker) issuerForObject(object string) (*x509.Certificate, error) {
 	prefix, _, found := strings.Cut(object, "/")
@@ -1,21 +1,32 @@
 package checker
 
 import (
+	"bytes"
 	"context"
 	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/stretchr/testify/require"
 
 	"github.com/letsencrypt/boulder/core"
 
+	"github.com/letsencrypt/crl-monitor/checker/crltest"
 	expirymock "github.com/letsencrypt/crl-monitor/checker/expiry/mock"
 	"github.com/letsencrypt/crl-monitor/checker/testdata"
 	"github.com/letsencrypt/crl-monitor/db"
 	dbmock "github.com/letsencrypt/crl-monitor/db/mock"
+	"github.com/letsencrypt/crl-monitor/logging"
+	metricsmock "github.com/letsencrypt/crl-monitor/metrics/mock"
+	"github.com/letsencrypt/crl-monitor/notify"
+	notifymock "github.com/letsencrypt/crl-monitor/notify/mock"
 	"github.com/letsencrypt/crl-monitor/storage"
 	storagemock "github.com/letsencrypt/crl-monitor/storage/mock"
 )
@@ -27,7 +38,7 @@ func TestCheck(t *testing.T) {
 	cert2expiry := testdata.Now.Add(3*time.Hour + 30*time.Minute)
 	fetcher.AddTestData(big.NewInt(2), cert2expiry)
 
-	issuer, key := testdata.MakeIssuer(t)
+	issuer, key := crltest.MakeIssuer(t)
 
 	issuerName := nameID(issuer)
 	shouldBeGood := fmt.Sprintf("%s/should-be-good.crl", issuerName)
@@ -37,18 +48,19 @@ func TestCheck(t *testing.T) {
 	earlyRemovalURL := fmt.Sprintf("http://idp/%s", earlyRemoval)
 	certificatesHaveCRLDPURL := fmt.Sprintf("http://idp/%s", certificatesHaveCRLDP)
 
-	crl1der := testdata.MakeCRL(t, &testdata.CRL1, shouldBeGoodURL, issuer, key)
-	crl2der := testdata.MakeCRL(t, &testdata.CRL2, shouldBeGoodURL, issuer, key)
-	crl3der := testdata.MakeCRL(t, &testdata.CRL3, earlyRemovalURL, issuer, key)
-	crl4der := testdata.MakeCRL(t, &testdata.CRL4, earlyRemovalURL, issuer, key)
-	crl6der := testdata.MakeCRL(t, &testdata.CRL6, certificatesHaveCRLDPURL, issuer, key)
-	crl7der := testdata.MakeCRL(t, &testdata.CRL7, certificatesHaveCRLDPURL, issuer, key)
+	crl1der := crltest.MakeCRL(t, &testdata.CRL1, shouldBeGoodURL, issuer, key)
+	crl2der := crltest.MakeCRL(t, &testdata.CRL2, shouldBeGoodURL, issuer, key)
+	crl3der := crltest.MakeCRL(t, &testdata.CRL3, earlyRemovalURL, issuer, key)
+	crl4der := crltest.MakeCRL(t, &testdata.CRL4, earlyRemovalURL, issuer, key)
+	crl6der := crltest.MakeCRL(t, &testdata.CRL6, certificatesHaveCRLDPURL, issuer, key)
+	crl7der := crltest.MakeCRL(t, &testdata.CRL7, certificatesHaveCRLDPURL, issuer, key)
 
 	data := map[string][]storagemock.MockObject{
 		shouldBeGood: {
 			{
-				VersionID: "the-current-version",
-				Data:      crl2der,
+				VersionID:    "the-current-version",
+				Data:         crl2der,
+				LastModified: testdata.Now.Add(3 * time.Hour).Truncate(time.Second),
 			},
 			{
 				VersionID: "the-previous-version",
@@ -77,46 +89,367 @@ func TestCheck(t *testing.T) {
 		},
 	}
 	bucket := "crl-test"
+	recorder := metricsmock.Recorder{}
+	notifier := notifymock.Recorder{}
 
-	checker := New(
-		dbmock.NewMockedDB(t),
-		storagemock.New(t, bucket, data),
-		&fetcher,
-		0,
-		24*time.Hour,
-		[]*x509.Certificate{issuer},
-	)
+	checker := New(Config{
+		DB:                      dbmock.NewMockedDB(t),
+		Storage:                 storagemock.New(t, bucket, data),
+		Fetcher:                 &fetcher,
+		FetchConcurrency:        1,
+		AgeLimit:                24 * time.Hour,
+		Issuers:                 []*x509.Certificate{issuer},
+		RevocationTimeTolerance: time.Minute,
+		Metrics:                 &recorder,
+		Notifier:                &notifier,
+	})
 
 	ctx := context.Background()
 
 	// Insert some serials in the "unseen-certificates" table to be checked.
 	serial := testdata.CRL1.RevokedCertificateEntries[0].SerialNumber
-	require.NoError(t, checker.db.AddCert(ctx, &x509.Certificate{SerialNumber: serial}, testdata.Now))
+	require.NoError(t, checker.db.AddCert(ctx, &x509.Certificate{SerialNumber: serial, CRLDistributionPoints: []string{shouldBeGoodURL}}, "", db.Revocation{Time: testdata.Now}))
 	shouldNotBeSeen := big.NewInt(12345)
-	require.NoError(t, checker.db.AddCert(ctx, &x509.Certificate{SerialNumber: shouldNotBeSeen}, testdata.Now))
+	require.NoError(t, checker.db.AddCert(ctx, &x509.Certificate{SerialNumber: shouldNotBeSeen, CRLDistributionPoints: []string{shouldBeGoodURL}}, "", db.Revocation{Time: testdata.Now}))
 	mismatchCRLDistributionPoint := big.NewInt(4213)
 
 	require.NoError(t, checker.Check(ctx, bucket, shouldBeGood, nil))
 
 	// We should have seen the monitored cert but not the 12345 serial
-	unseenCerts, err := checker.db.GetAllCerts(ctx)
-	require.NoError(t, err)
+	unseenCerts := dbmock.Certs(t, checker.db)
 	serialString := db.NewCertKey(shouldNotBeSeen).SerialString()
 	require.Contains(t, unseenCerts, serialString)
 	delete(unseenCerts, serialString)
 	require.Empty(t, unseenCerts)
 
 	// The "early-removal" object should error on a certificate removed early
-	require.ErrorContains(t, checker.Check(ctx, bucket, earlyRemoval, nil), "early removal of 1 certificates detected!")
+	err := checker.Check(ctx, bucket, earlyRemoval, nil)
+	require.ErrorContains(t, err, "early removal of 1 certificates detected!")
+	var earlyRemovalErr *EarlyRemovalError
+	require.ErrorAs(t, err, &earlyRemovalErr)
+	require.Equal(t, 1, earlyRemovalErr.Count)
+	require.Equal(t, big.NewInt(2), earlyRemovalErr.Sample[0].Serial)
+	require.Equal(t, "the-current-version", earlyRemovalErr.CRLs.New.StorageKey.Version)
+	require.True(t, IsViolation(err))
+	require.Equal(t, []float64{1}, recorder.Values("Violations"))
+	require.Equal(t, []notify.Alert{{
+		Source:   "checker",
+		Type:     "EarlyRemovalError",
+		Message:  err.Error(),
+		Bucket:   bucket,
+		Shard:    earlyRemoval,
+		Versions: []string{"the-previous-version", "the-current-version"},
+		Serials:  []string{"000000000000000000000000000000000002"},
+	}}, notifier.Alerts())
+
+	// A version that can't be fetched is a transient error, not a violation
+	err = checker.Check(ctx, bucket, earlyRemoval, aws.String("no-such-version"))
+	var storageErr *StorageError
+	require.ErrorAs(t, err, &storageErr)
+	require.Equal(t, "no-such-version", storageErr.Key.VersionString())
+	require.False(t, IsViolation(err))
+	require.Equal(t, []float64{1}, recorder.Values("TransientErrors"))
+	// Transient errors aren't sent as alerts
+	require.Len(t, notifier.Alerts(), 1)
+
+	require.Equal(t, []float64{3, 1}, recorder.Values("NumEntries"))
+	require.Equal(t, []float64{0, 0}, recorder.Values("SerialsAdded"))
+	require.Equal(t, []float64{0, 1}, recorder.Values("SerialsRemoved"))
+	require.Equal(t, []float64{0, 1}, recorder.Values("EarlyRemovalSampleSize"))
+	// The monitored cert was revoked at Now, and CRL2 is from two hours later
+	require.Equal(t, []float64{7200}, recorder.Values("RevocationToCRL"))
+	require.Equal(t, []float64{10800}, recorder.Values("RevocationToUpload"))
+	summary, err := checker.db.GetLatencySummary(ctx, testdata.Now.Add(3*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Hour, db.Percentile(summary.ThisUpdate, 100))
+	require.Equal(t, 3*time.Hour, db.Percentile(summary.Uploaded, 100))
 
 	require.NoError(t, checker.db.AddCert(ctx, &x509.Certificate{
 		SerialNumber: mismatchCRLDistributionPoint,
 		CRLDistributionPoints: []string{
 			"http://example.com",
 		},
-	}, testdata.Now))
-	// The "certificates-have-crldp" object should error because the certificate CRL is a mismatch
-	require.ErrorContains(t, checker.Check(ctx, bucket, certificatesHaveCRLDP, nil), "has non-matching CRLDistributionPoint")
+	}, "", db.Revocation{Time: testdata.Now}))
+	// The "certificates-have-crldp" object only looks for certs belonging to its
+	// own shard, so the mismatched cert stays pending for the churner to report
+	require.NoError(t, checker.Check(ctx, bucket, certificatesHaveCRLDP, nil))
+	unseenCerts = dbmock.Certs(t, checker.db)
+	require.Contains(t, unseenCerts, db.NewCertKey(mismatchCRLDistributionPoint).SerialString())
+}
+
+func TestCheckFilesystem(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	issuerName := nameID(issuer)
+	object := fmt.Sprintf("%s/7.crl", issuerName)
+	idpURL := fmt.Sprintf("http://idp/%s", object)
+
+	// Fresh copies, as MakeCRL adds an IDP extension to its input
+	serial := big.NewInt(77)
+	prevDER := crltest.MakeCRL(t, &x509.RevocationList{
+		ThisUpdate: testdata.Now,
+		NextUpdate: testdata.Now.Add(24 * time.Hour),
+		Number:     big.NewInt(1),
+	}, idpURL, issuer, key)
+	curDER := crltest.MakeCRL(t, &x509.RevocationList{
+		ThisUpdate: testdata.Now.Add(time.Hour),
+		NextUpdate: testdata.Now.Add(24 * time.Hour),
+		Number:     big.NewInt(2),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: serial, RevocationTime: testdata.Now, ReasonCode: testdata.CessationOfOperation},
+		},
+	}, idpURL, issuer, key)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test-7-2026-06-01T00:00:00-first.crl"), prevDER, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test-7-2026-06-01T01:00:00-second.crl"), curDER, 0o600))
+
+	fs, err := storage.NewFilesystem(dir, map[string]string{issuerName: "test"})
+	require.NoError(t, err)
+	checker := New(Config{
+		DB:                      dbmock.NewMockedDB(t),
+		Storage:                 fs,
+		Fetcher:                 &expirymock.Fetcher{},
+		FetchConcurrency:        1,
+		AgeLimit:                24 * time.Hour,
+		Issuers:                 []*x509.Certificate{issuer},
+		RevocationTimeTolerance: time.Minute,
+	})
+
+	ctx := context.Background()
+	require.NoError(t, checker.db.AddCert(ctx, &x509.Certificate{SerialNumber: serial, CRLDistributionPoints: []string{idpURL}}, "", db.Revocation{Time: testdata.Now}))
+
+	require.NoError(t, checker.Check(ctx, "", object, nil))
+
+	unseenCerts := dbmock.Certs(t, checker.db)
+	require.Empty(t, unseenCerts)
+}
+
+func TestCheckReadded(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	object := fmt.Sprintf("%s/5.crl", nameID(issuer))
+	idpURL := fmt.Sprintf("http://idp/%s", object)
+
+	fetcher := expirymock.Fetcher{}
+	// Serial 1 had already expired, so removing it isn't early
+	fetcher.AddTestData(big.NewInt(1), testdata.Now.Add(-time.Hour))
+
+	makeCRL := func(number int64, serials ...int64) []byte {
+		var entries []x509.RevocationListEntry
+		for _, serial := range serials {
+			entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: testdata.Now})
+		}
+		return crltest.MakeCRL(t, &x509.RevocationList{
+			ThisUpdate:                testdata.Now.Add(time.Duration(number) * time.Hour),
+			NextUpdate:                testdata.Now.Add(24 * time.Hour),
+			Number:                    big.NewInt(number),
+			RevokedCertificateEntries: entries,
+		}, idpURL, issuer, key)
+	}
+
+	bucket := "crl-test"
+	data := map[string][]storagemock.MockObject{
+		object: {
+			{VersionID: "v4", Data: makeCRL(4, 1, 2, 3)},
+			{VersionID: "v3", Data: makeCRL(3, 2, 3)},
+			{VersionID: "v2", Data: makeCRL(2, 2)},
+			{VersionID: "v1", Data: makeCRL(1, 1, 2)},
+		},
+	}
+
+	for _, tt := range []struct {
+		name        string
+		window      int
+		version     string
+		expectedErr string
+		// removedVersion is the version the re-added serial is expected to
+		// have first been missing from, if it's checked
+		removedVersion string
+	}{
+		{name: "disabled", window: 0, version: "v4"},
+		{name: "removal outside window", window: 2, version: "v4"},
+		{name: "removal inside window", window: 3, version: "v4", expectedErr: "1 serials re-added after removal detected!"},
+		{name: "window larger than history", window: 10, version: "v4", expectedErr: "1 serials re-added after removal detected!", removedVersion: "v2"},
+		{name: "nothing readded", window: 10, version: "v3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := New(Config{
+				DB:                      dbmock.NewMockedDB(t),
+				Storage:                 storagemock.New(t, bucket, data),
+				Fetcher:                 &fetcher,
+				FetchConcurrency:        1,
+				AgeLimit:                24 * time.Hour,
+				Issuers:                 []*x509.Certificate{issuer},
+				ReaddWindow:             tt.window,
+				RevocationTimeTolerance: time.Minute,
+			})
+			err := checker.Check(context.Background(), bucket, object, &tt.version)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.expectedErr)
+			}
+			if tt.removedVersion != "" {
+				var readdedErr *ReaddedError
+				require.ErrorAs(t, err, &readdedErr)
+				require.Equal(t, tt.removedVersion, readdedErr.Sample[0].RemovedVersion)
+				require.Equal(t, tt.version, readdedErr.Sample[0].ReaddedVersion)
+			}
+		})
+	}
+}
+
+func TestCheckMutations(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	object := fmt.Sprintf("%s/9.crl", nameID(issuer))
+	idpURL := fmt.Sprintf("http://idp/%s", object)
+
+	makeCRL := func(number int64, reasonCode int) []byte {
+		return crltest.MakeCRL(t, &x509.RevocationList{
+			ThisUpdate: testdata.Now.Add(time.Duration(number) * time.Hour),
+			NextUpdate: testdata.Now.Add(24 * time.Hour),
+			Number:     big.NewInt(number),
+			RevokedCertificateEntries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(1), RevocationTime: testdata.Now, ReasonCode: reasonCode},
+			},
+		}, idpURL, issuer, key)
+	}
+
+	bucket := "crl-test"
+	data := map[string][]storagemock.MockObject{
+		object: {
+			{VersionID: "v3", Data: makeCRL(3, 4)},
+			{VersionID: "v2", Data: makeCRL(2, 1)},
+			{VersionID: "v1", Data: makeCRL(1, 5)},
+		},
+	}
+	checker := New(Config{
+		DB:                      dbmock.NewMockedDB(t),
+		Storage:                 storagemock.New(t, bucket, data),
+		Fetcher:                 &expirymock.Fetcher{},
+		FetchConcurrency:        1,
+		AgeLimit:                24 * time.Hour,
+		Issuers:                 []*x509.Certificate{issuer},
+		RevocationTimeTolerance: time.Minute,
+	})
+	ctx := context.Background()
+
+	// Updating the reason to keyCompromise is fine, but not away from it
+	require.NoError(t, checker.Check(ctx, bucket, object, aws.String("v2")))
+	require.ErrorContains(t, checker.Check(ctx, bucket, object, aws.String("v3")), "1 entries changed between versions!")
+}
+
+func TestCheckRevocationTime(t *testing.T) {
+	checker := New(Config{
+		FetchConcurrency:        1,
+		AgeLimit:                24 * time.Hour,
+		RevocationTimeTolerance: 5 * time.Minute,
+	})
+
+	issued := testdata.Now.Add(-time.Hour)
+	revoked := testdata.Now
+
+	for _, tt := range []struct {
+		name        string
+		crlTime     time.Time
+		notBefore   time.Time
+		expectedErr string
+	}{
+		{name: "exact", crlTime: revoked, notBefore: issued},
+		{name: "within tolerance", crlTime: revoked.Add(-4 * time.Minute), notBefore: issued},
+		{name: "no issuance time recorded", crlTime: revoked.Add(time.Minute)},
+		{name: "too late", crlTime: revoked.Add(6 * time.Minute), notBefore: issued, expectedErr: "differs from recorded revocation time"},
+		{name: "too early", crlTime: revoked.Add(-6 * time.Minute), notBefore: issued, expectedErr: "differs from recorded revocation time"},
+		{name: "before issuance", crlTime: revoked.Add(-2 * time.Minute), notBefore: revoked.Add(-time.Minute), expectedErr: "is before issuance time"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checker.checkRevocationTime(
+				x509.RevocationListEntry{SerialNumber: big.NewInt(1), RevocationTime: tt.crlTime},
+				db.CertMetadata{RevocationTime: revoked, NotBefore: tt.notBefore},
+			)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestCheckIncompleteEarlyRemoval(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	object := fmt.Sprintf("%s/6.crl", nameID(issuer))
+	shard := crltest.NewShard(t, issuer, key, fmt.Sprintf("http://idp/%s", object), time.Now().Add(-2*time.Hour))
+
+	serials := shard.Revoke(10, time.Now().Add(90*24*time.Hour), 1)
+	shard.Next()
+	shard.Remove(serials...)
+	churned := shard.Revoke(1, time.Now().Add(90*24*time.Hour), 5)
+	shard.Next()
+
+	// One lookup fails, but the rest are still fetched
+	fetcher := shard.Fetcher()
+	fetcher.Fail(serials[4], errors.New("certinfo unavailable"))
+
+	bucket := "crl-test"
+	checker := New(Config{
+		DB:                      dbmock.NewMockedDB(t),
+		Storage:                 storagemock.New(t, bucket, map[string][]storagemock.MockObject{object: shard.MockObjects()}),
+		Fetcher:                 fetcher,
+		FetchConcurrency:        1,
+		FetchTimeout:            time.Minute,
+		AgeLimit:                24 * time.Hour,
+		Issuers:                 []*x509.Certificate{issuer},
+		RevocationTimeTolerance: time.Minute,
+	})
+
+	ctx := context.Background()
+	revoked := db.Revocation{Time: shard.Versions()[1].ThisUpdate, Method: db.RevokedByAccount, ReasonCode: 5}
+	require.NoError(t, checker.db.AddCert(ctx, &x509.Certificate{SerialNumber: churned[0], CRLDistributionPoints: []string{fmt.Sprintf("http://idp/%s", object)}}, "", revoked))
+
+	err := checker.Check(ctx, bucket, object, nil)
+	require.True(t, IsViolation(err))
+
+	var earlyRemoval *EarlyRemovalError
+	require.ErrorAs(t, err, &earlyRemoval)
+	require.Equal(t, 9, earlyRemoval.Count)
+
+	var lookup *LookupError
+	require.ErrorAs(t, err, &lookup)
+	require.ErrorContains(t, lookup, "fetched 9 of 10 sampled serials")
+
+	// The churned cert was still seen and deleted
+	unseenCerts := dbmock.Certs(t, checker.db)
+	require.Empty(t, unseenCerts)
+}
+
+func TestCheckEarlyRemovalOrder(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	otherIssuer, otherKey := crltest.MakeIssuer(t)
+	object := fmt.Sprintf("%s/6.crl", nameID(issuer))
+	shard := crltest.NewShard(t, issuer, key, fmt.Sprintf("http://idp/%s", object), time.Now().Add(-2*time.Hour))
+	otherShard := crltest.NewShard(t, otherIssuer, otherKey, fmt.Sprintf("http://idp/%s", object), time.Now().Add(-time.Hour))
+
+	checker := New(Config{
+		DB:                      dbmock.NewMockedDB(t),
+		Storage:                 storagemock.New(t, "crl-test", nil),
+		Fetcher:                 shard.Fetcher(),
+		FetchConcurrency:        1,
+		FetchTimeout:            time.Minute,
+		AgeLimit:                24 * time.Hour,
+		Issuers:                 []*x509.Certificate{issuer},
+		RevocationTimeTolerance: time.Minute,
+	})
+
+	// CRLs from different issuers can't be diffed, which is a violation, not
+	// a failure to look up certificates
+	prev := shard.Next()
+	crl := otherShard.Next()
+	err := checker.checkEarlyRemoval(context.Background(), prev, storage.Key{Object: object}, crl, storage.Key{Object: object})
+	require.True(t, IsViolation(err))
+	var orderErr *OrderError
+	require.ErrorAs(t, err, &orderErr)
+	var lookup *LookupError
+	require.False(t, errors.As(err, &lookup))
 }
 
 func Test_nameID(t *testing.T) {
@@ -167,13 +500,13 @@ func Test_nameID(t *testing.T) {
 }
 
 func TestLogSummaryFormatsVersionCorrectly(t *testing.T) {
-	issuer, key := testdata.MakeIssuer(t)
+	issuer, key := crltest.MakeIssuer(t)
 	issuerName := nameID(issuer)
 	object := fmt.Sprintf("%s/0.crl", issuerName)
 	idpURL := fmt.Sprintf("http://idp/%s", object)
 
-	crl1der := testdata.MakeCRL(t, &testdata.CRL1, idpURL, issuer, key)
-	crl2der := testdata.MakeCRL(t, &testdata.CRL2, idpURL, issuer, key)
+	crl1der := crltest.MakeCRL(t, &testdata.CRL1, idpURL, issuer, key)
+	crl2der := crltest.MakeCRL(t, &testdata.CRL2, idpURL, issuer, key)
 
 	crl1, err := x509.ParseRevocationList(crl1der)
 	require.NoError(t, err)
@@ -193,3 +526,44 @@ func TestLogSummaryFormatsVersionCorrectly(t *testing.T) {
 	require.Contains(t, formatted, "oldy")
 	require.Contains(t, formatted, "newy")
 }
+
+func TestCheckLogsRequestAttributes(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	object := fmt.Sprintf("%s/5.crl", nameID(issuer))
+	idpURL := fmt.Sprintf("http://idp/%s", object)
+
+	data := map[string][]storagemock.MockObject{
+		object: {
+			{VersionID: "v2", Data: crltest.MakeCRL(t, &x509.RevocationList{ThisUpdate: testdata.Now.Add(time.Hour), NextUpdate: testdata.Now.Add(24 * time.Hour), Number: big.NewInt(2)}, idpURL, issuer, key)},
+			{VersionID: "v1", Data: crltest.MakeCRL(t, &x509.RevocationList{ThisUpdate: testdata.Now, NextUpdate: testdata.Now.Add(24 * time.Hour), Number: big.NewInt(1)}, idpURL, issuer, key)},
+		},
+	}
+	bucket := "crl-test"
+	checker := New(Config{
+		DB:                      dbmock.NewMockedDB(t),
+		Storage:                 storagemock.New(t, bucket, data),
+		Fetcher:                 &expirymock.Fetcher{},
+		FetchConcurrency:        1,
+		AgeLimit:                24 * time.Hour,
+		Issuers:                 []*x509.Certificate{issuer},
+		RevocationTimeTolerance: time.Minute,
+	})
+
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), logging.New(&buf))
+	ctx, _ = logging.With(ctx, logging.RequestID, "request-1")
+	require.NoError(t, checker.Check(ctx, bucket, object, nil))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.NotEmpty(t, lines)
+	for _, line := range lines {
+		var attrs map[string]any
+		require.NoError(t, json.Unmarshal(line, &attrs))
+		require.Equal(t, "request-1", attrs[logging.RequestID], string(line))
+		require.Equal(t, bucket, attrs[logging.Bucket], string(line))
+		require.Equal(t, object, attrs[logging.Object], string(line))
+		require.Equal(t, "v2", attrs[logging.Version], string(line))
+		require.Equal(t, "2", attrs[logging.CRLNumber], string(line))
+		require.Equal(t, idpURL, attrs[logging.IDP], string(line))
+	}
+}
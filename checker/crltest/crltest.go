@@ -0,0 +1,343 @@
+// Package crltest generates signed CRLs for tests: single CRLs, or a sequence
+// of versions of a shard with controllable churn of serials, expiry dates,
+// reason codes, numbering glitches and signature faults. A Fetcher knows the
+// NotAfter of every serial a Shard has revoked, to check removals against.
+package crltest
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	storagemock "github.com/letsencrypt/crl-monitor/storage/mock"
+)
+
+// MakeIssuer returns a new self-signed issuer and its key.
+func MakeIssuer(t *testing.T) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test-issuer"},
+		SerialNumber:          big.NewInt(123434235),
+		KeyUsage:              x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certDER)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+// MakeCRL takes a revocation list and issuer to sign it.  It returns a DER encoded CRL.
+func MakeCRL(t *testing.T, input *x509.RevocationList, idp string, issuer *x509.Certificate, key crypto.Signer) []byte {
+	ext, err := IDPExtension(idp)
+	require.NoError(t, err)
+
+	input.ExtraExtensions = append(input.ExtraExtensions, ext)
+	der, err := x509.CreateRevocationList(rand.Reader, input, issuer, key)
+	require.NoError(t, err)
+	return der
+}
+
+// IDPExtension returns an IssuingDistributionPoint extension with a single
+// URI, marked as only containing end-entity certificates, as Boulder does.
+func IDPExtension(issuingDistributionPoint string) (pkix.Extension, error) {
+	type distributionPointName struct {
+		FullName []asn1.RawValue `asn1:"optional,tag:0"`
+	}
+	val := struct {
+		DistributionPoint     distributionPointName `asn1:"optional,tag:0"`
+		OnlyContainsUserCerts bool                  `asn1:"optional,tag:1"`
+	}{
+		DistributionPoint: distributionPointName{
+			[]asn1.RawValue{ // GeneralNames
+				{ // GeneralName
+					Class: 2, // context-specific
+					Tag:   6, // uniformResourceIdentifier, IA5String
+					Bytes: []byte(issuingDistributionPoint),
+				},
+			},
+		},
+		OnlyContainsUserCerts: true,
+	}
+
+	valBytes, err := asn1.Marshal(val)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{
+		Id:       asn1.ObjectIdentifier{2, 5, 29, 28}, // id-ce-issuingDistributionPoint
+		Value:    valBytes,
+		Critical: true,
+	}, nil
+}
+
+// Fault is a problem to introduce into one version of a Shard.
+type Fault int
+
+const (
+	// RepeatNumber reuses the previous version's CRL number.
+	RepeatNumber Fault = iota
+	// DecreaseNumber uses a CRL number lower than the previous version's.
+	DecreaseNumber
+	// RewindThisUpdate uses a thisUpdate before the previous version's.
+	RewindThisUpdate
+	// WrongKey signs with a key other than the issuer's.
+	WrongKey
+	// CorruptSignature flips a bit in the signature.
+	CorruptSignature
+	// OtherIDP uses the IssuingDistributionPoint of a different shard.
+	OtherIDP
+	// NoIDP leaves out the IssuingDistributionPoint.
+	NoIDP
+)
+
+// Shard generates successive versions of a CRL shard. Serials are revoked
+// with Revoke, and removed with RemoveExpired, like a CA does, or with
+// Remove. Each call to Next signs a version with the serials revoked at
+// that point, one Interval after the last.
+type Shard struct {
+	t      *testing.T
+	issuer *x509.Certificate
+	key    crypto.Signer
+	idp    string
+
+	// Interval is the time between the thisUpdate of each version.
+	Interval time.Duration
+
+	now     time.Time
+	number  int64
+	serial  int64
+	entries []x509.RevocationListEntry
+	// versions holds every version signed so far, oldest first
+	versions []*x509.RevocationList
+	fetcher  *Fetcher
+}
+
+// NewShard returns a Shard of issuer's CRLs with the IssuingDistributionPoint
+// idp. The first version's thisUpdate is start, and the Interval is an hour.
+func NewShard(t *testing.T, issuer *x509.Certificate, key crypto.Signer, idp string, start time.Time) *Shard {
+	return &Shard{
+		t:        t,
+		issuer:   issuer,
+		key:      key,
+		idp:      idp,
+		Interval: time.Hour,
+		now:      start,
+		fetcher:  &Fetcher{notAfter: make(map[string]time.Time)},
+	}
+}
+
+// Now is the thisUpdate of the next version.
+func (s *Shard) Now() time.Time {
+	return s.now
+}
+
+// Revoke adds count new serials to the shard, revoked now with reason, for
+// certificates expiring at notAfter. It returns the new serials.
+func (s *Shard) Revoke(count int, notAfter time.Time, reason int) []*big.Int {
+	var serials []*big.Int
+	for range count {
+		s.serial++
+		serial := big.NewInt(s.serial)
+		s.entries = append(s.entries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: s.now,
+			ReasonCode:     reason,
+		})
+		s.fetcher.add(serial, notAfter)
+		serials = append(serials, serial)
+	}
+	return serials
+}
+
+// RemoveExpired removes the serials of certificates which expired before the
+// previous version, which is when a CA may drop them. It returns the serials
+// removed.
+func (s *Shard) RemoveExpired() []*big.Int {
+	if len(s.versions) == 0 {
+		return nil
+	}
+	previous := s.versions[len(s.versions)-1].ThisUpdate
+
+	var removed []*big.Int
+	for _, entry := range s.entries {
+		notAfter, _ := s.fetcher.lookup(entry.SerialNumber)
+		if notAfter.Before(previous) {
+			removed = append(removed, entry.SerialNumber)
+		}
+	}
+	s.Remove(removed...)
+	return removed
+}
+
+// Remove removes serials from the shard, whether or not they have expired.
+func (s *Shard) Remove(serials ...*big.Int) {
+	s.entries = slices.DeleteFunc(s.entries, func(entry x509.RevocationListEntry) bool {
+		return slices.ContainsFunc(serials, func(serial *big.Int) bool {
+			return serial.Cmp(entry.SerialNumber) == 0
+		})
+	})
+}
+
+// Next signs and returns the next version of the shard, with any faults.
+// Faulty numbers and times don't affect later versions.
+func (s *Shard) Next(faults ...Fault) *x509.RevocationList {
+	s.number++
+	number := s.number
+	thisUpdate := s.now
+	key := s.key
+	idp := s.idp
+	for _, fault := range faults {
+		switch fault {
+		case RepeatNumber:
+			number = s.number - 1
+		case DecreaseNumber:
+			number = s.number - 2
+		case RewindThisUpdate:
+			thisUpdate = s.now.Add(-2 * s.Interval)
+		case WrongKey:
+			var err error
+			key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			require.NoError(s.t, err)
+		case OtherIDP:
+			idp = s.idp + ".other"
+		}
+	}
+
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(number),
+		ThisUpdate:                thisUpdate,
+		NextUpdate:                thisUpdate.Add(24 * time.Hour),
+		RevokedCertificateEntries: slices.Clone(s.entries),
+	}
+	var der []byte
+	if slices.Contains(faults, NoIDP) {
+		var err error
+		der, err = x509.CreateRevocationList(rand.Reader, template, s.issuer, key)
+		require.NoError(s.t, err)
+	} else {
+		der = MakeCRL(s.t, template, idp, s.issuer, key)
+	}
+	if slices.Contains(faults, CorruptSignature) {
+		// The signature is the last field, so its last byte is the CRL's
+		der[len(der)-1] ^= 1
+	}
+
+	crl, err := x509.ParseRevocationList(der)
+	require.NoError(s.t, err)
+	s.versions = append(s.versions, crl)
+	s.now = s.now.Add(s.Interval)
+	return crl
+}
+
+// Versions returns every version signed so far, oldest first.
+func (s *Shard) Versions() []*x509.RevocationList {
+	return slices.Clone(s.versions)
+}
+
+// MockObjects returns every version signed so far, newest first, for
+// storage/mock. Each version is uploaded at its thisUpdate, with the version
+// ID "v" followed by its position in the sequence, starting at "v1".
+func (s *Shard) MockObjects() []storagemock.MockObject {
+	var objects []storagemock.MockObject
+	for i, crl := range s.versions {
+		objects = slices.Insert(objects, 0, storagemock.MockObject{
+			VersionID:    fmt.Sprintf("v%d", i+1),
+			Data:         crl.Raw,
+			LastModified: crl.ThisUpdate,
+		})
+	}
+	return objects
+}
+
+// Fetcher returns an earlyremoval.Fetcher which knows the NotAfter of every
+// serial revoked in the shard.
+func (s *Shard) Fetcher() *Fetcher {
+	return s.fetcher
+}
+
+// Fetcher is a fake earlyremoval.Fetcher, which returns the NotAfter of
+// serials revoked by a Shard, or errors for unknown serials. It counts the
+// serials it's asked for, and can be made to fail or be slow.
+type Fetcher struct {
+	// Delay is how long each fetch takes, unless its context is done first.
+	Delay time.Duration
+
+	mu       sync.Mutex
+	notAfter map[string]time.Time
+	errs     map[string]error
+	fetches  int
+}
+
+func (f *Fetcher) add(serial *big.Int, notAfter time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notAfter[serial.String()] = notAfter
+}
+
+func (f *Fetcher) lookup(serial *big.Int) (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	notAfter, ok := f.notAfter[serial.String()]
+	return notAfter, ok
+}
+
+// Fail makes fetching serial return err.
+func (f *Fetcher) Fail(serial *big.Int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.errs == nil {
+		f.errs = make(map[string]error)
+	}
+	f.errs[serial.String()] = err
+}
+
+// Fetches returns how many times FetchNotAfter has been called.
+func (f *Fetcher) Fetches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches
+}
+
+func (f *Fetcher) FetchNotAfter(ctx context.Context, serial *big.Int) (time.Time, error) {
+	f.mu.Lock()
+	f.fetches++
+	notAfter, known := f.notAfter[serial.String()]
+	err := f.errs[serial.String()]
+	f.mu.Unlock()
+
+	if f.Delay > 0 {
+		select {
+		case <-time.After(f.Delay):
+		case <-ctx.Done():
+			return time.Time{}, ctx.Err()
+		}
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !known {
+		return time.Time{}, fmt.Errorf("unknown serial %d", serial)
+	}
+	return notAfter, nil
+}
@@ -0,0 +1,118 @@
+package crltest
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/crl/checker"
+	"github.com/letsencrypt/boulder/crl/idp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/checker/earlyremoval"
+)
+
+var _ earlyremoval.Fetcher = &Fetcher{}
+
+func TestShard(t *testing.T) {
+	issuer, key := MakeIssuer(t)
+	start := time.Now().Add(-6 * time.Hour)
+	shard := NewShard(t, issuer, key, "http://c.example/1.crl", start)
+
+	shortLived := shard.Revoke(2, start.Add(30*time.Minute), 1)
+	longLived := shard.Revoke(3, start.Add(48*time.Hour), 5)
+	first := shard.Next()
+	require.NoError(t, checker.Validate(first, issuer, 24*time.Hour))
+	require.WithinDuration(t, start, first.ThisUpdate, time.Second)
+	require.Len(t, first.RevokedCertificateEntries, 5)
+	require.Equal(t, 1, first.RevokedCertificateEntries[0].ReasonCode)
+	require.Equal(t, 5, first.RevokedCertificateEntries[4].ReasonCode)
+	idps, err := idp.GetIDPURIs(first.Extensions)
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://c.example/1.crl"}, idps)
+
+	// Nothing has expired before the first version yet
+	require.Empty(t, shard.RemoveExpired())
+	second := shard.Next()
+	// The short-lived certs expired before the second version
+	require.Equal(t, shortLived, shard.RemoveExpired())
+	third := shard.Next()
+	require.Len(t, third.RevokedCertificateEntries, len(longLived))
+
+	require.Equal(t, int64(3), third.Number.Int64())
+	require.Equal(t, shard.Interval, third.ThisUpdate.Sub(second.ThisUpdate))
+	require.Equal(t, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}, []*big.Int{first.Number, second.Number, third.Number})
+
+	diff, err := checker.Diff(second, third)
+	require.NoError(t, err)
+	require.Equal(t, shortLived, diff.Removed)
+
+	objects := shard.MockObjects()
+	require.Len(t, objects, 3)
+	require.Equal(t, "v3", objects[0].VersionID)
+	require.Equal(t, third.Raw, objects[0].Data)
+	require.Equal(t, shard.Versions()[0].Raw, objects[2].Data)
+}
+
+func TestShardFaults(t *testing.T) {
+	issuer, key := MakeIssuer(t)
+	shard := NewShard(t, issuer, key, "http://c.example/1.crl", time.Now().Add(-time.Hour))
+	shard.Revoke(1, time.Now().Add(time.Hour), 0)
+	first := shard.Next()
+
+	repeated := shard.Next(RepeatNumber)
+	require.Equal(t, first.Number, repeated.Number)
+
+	second := shard.Next()
+	decreased := shard.Next(DecreaseNumber)
+	require.Equal(t, -1, decreased.Number.Cmp(second.Number))
+
+	third := shard.Next()
+	rewound := shard.Next(RewindThisUpdate)
+	require.True(t, rewound.ThisUpdate.Before(third.ThisUpdate))
+
+	// Faults don't carry over to the next version
+	next := shard.Next()
+	require.Equal(t, int64(7), next.Number.Int64())
+	require.True(t, next.ThisUpdate.After(third.ThisUpdate))
+	require.NoError(t, next.CheckSignatureFrom(issuer))
+
+	require.Error(t, shard.Next(WrongKey).CheckSignatureFrom(issuer))
+	require.Error(t, shard.Next(CorruptSignature).CheckSignatureFrom(issuer))
+
+	idps, err := idp.GetIDPURIs(shard.Next(OtherIDP).Extensions)
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://c.example/1.crl.other"}, idps)
+	_, err = idp.GetIDPURIs(shard.Next(NoIDP).Extensions)
+	require.ErrorContains(t, err, "no IssuingDistributionPoint")
+}
+
+func TestFetcher(t *testing.T) {
+	issuer, key := MakeIssuer(t)
+	shard := NewShard(t, issuer, key, "http://c.example/1.crl", time.Now())
+	notAfter := time.Now().Add(time.Hour)
+	serials := shard.Revoke(2, notAfter, 0)
+	fetcher := shard.Fetcher()
+	ctx := context.Background()
+
+	got, err := fetcher.FetchNotAfter(ctx, serials[0])
+	require.NoError(t, err)
+	require.Equal(t, notAfter, got)
+
+	_, err = fetcher.FetchNotAfter(ctx, big.NewInt(1000))
+	require.ErrorContains(t, err, "unknown serial 1000")
+
+	failure := errors.New("certinfo unavailable")
+	fetcher.Fail(serials[1], failure)
+	_, err = fetcher.FetchNotAfter(ctx, serials[1])
+	require.ErrorIs(t, err, failure)
+	require.Equal(t, 3, fetcher.Fetches())
+
+	fetcher.Delay = time.Hour
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = fetcher.FetchNotAfter(canceled, serials[0])
+	require.ErrorIs(t, err, context.Canceled)
+}
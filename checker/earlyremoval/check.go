@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"crypto/x509"
-	"log"
+	"fmt"
 	"math/big"
 	"math/rand/v2"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/letsencrypt/boulder/crl/checker"
+
+	"github.com/letsencrypt/crl-monitor/logging"
 )
 
 type Fetcher interface {
@@ -52,8 +56,61 @@ func sample[T any](input []T, max int) []T {
 	return sampled
 }
 
-// Check for early removal.  If maxFetch is greater than 0, only check that many serials
-func Check(ctx context.Context, fetcher Fetcher, maxFetch int, prev *x509.RevocationList, crl *x509.RevocationList) ([]EarlyRemoval, error) {
+// FetchFailure is a sampled serial whose NotAfter couldn't be fetched.
+type FetchFailure struct {
+	Serial *big.Int
+	Err    error
+}
+
+// IncompleteError is returned by Check, along with the early removals found so
+// far, when not every sampled serial could be fetched: either some fetches
+// failed, or ctx was done before the fetches finished.
+type IncompleteError struct {
+	// Fetched is how many of the Sampled serials were checked
+	Fetched int
+	Sampled int
+	// Failed are the serials whose fetch failed, in the order they were
+	// sampled. Serials left unfetched because ctx was done aren't included.
+	Failed []FetchFailure
+	// Err is why ctx was done, if it was
+	Err error
+}
+
+func (e *IncompleteError) Error() string {
+	msg := fmt.Sprintf("early removal check incomplete: fetched %d of %d sampled serials", e.Fetched, e.Sampled)
+	if len(e.Failed) != 0 {
+		msg += fmt.Sprintf(": %d failed, first %x: %v", len(e.Failed), e.Failed[0].Serial, e.Failed[0].Err)
+	}
+	if e.Err != nil {
+		msg += fmt.Sprintf(": %v", e.Err)
+	}
+	return msg
+}
+
+func (e *IncompleteError) Unwrap() []error {
+	var errs []error
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	for _, failure := range e.Failed {
+		errs = append(errs, failure.Err)
+	}
+	return errs
+}
+
+// Check for early removal.  If maxFetch is greater than 0, only check that
+// many serials. Up to concurrency serials are fetched at once, or one at a
+// time if it's not greater than 0.
+//
+// A serial which fails to fetch is recorded, and the rest are still fetched.
+// If ctx is done, the remaining fetches are abandoned. Either way, the early
+// removals found are returned with an *IncompleteError.
+//
+// Any other error means prev and crl couldn't be diffed. That happens when
+// they have different issuers, or are out of order.
+func Check(ctx context.Context, fetcher Fetcher, maxFetch int, concurrency int, prev *x509.RevocationList, crl *x509.RevocationList) ([]EarlyRemoval, error) {
+	logger := logging.FromContext(ctx)
+
 	// In rare cases, a duplicate CRL version may be uploaded. This causes a flake,
 	// because checker.Diff() expects CRLs to be increasing in version number. It is
 	// valid for duplicate versions to be uploaded, as long as they're bit-for-bit
@@ -63,7 +120,7 @@ func Check(ctx context.Context, fetcher Fetcher, maxFetch int, prev *x509.Revoca
 	// previous CRL version already, so we don't have any work to do on the newer
 	// version.
 	if len(crl.Raw) > 0 && bytes.Equal(prev.Raw, crl.Raw) {
-		log.Printf("previous and current CRL (number %d) are identical; skipping early removal check", crl.Number)
+		logger.Info("previous and current CRL are identical; skipping early removal check")
 		return nil, nil
 	}
 
@@ -78,29 +135,81 @@ func Check(ctx context.Context, fetcher Fetcher, maxFetch int, prev *x509.Revoca
 	} else {
 		sampled = diff.Removed
 	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
 
-	log.Printf("checking for early CRL removal on %d of %d serials", len(sampled), len(diff.Removed))
+	logger.Info("checking for early CRL removal", "sampled", len(sampled), "removed", len(diff.Removed), "concurrency", concurrency)
 
-	var earlyRemovals []EarlyRemoval
+	type result struct {
+		notAfter time.Time
+		fetched  bool
+		err      error
+	}
+	results := make([]result, len(sampled))
+	indexes := make(chan int)
+	// completed counts fetches that have returned, for logging progress
+	var completed atomic.Int64
+
+	var wg sync.WaitGroup
+	for range min(concurrency, len(sampled)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				// Each index is handed to exactly one worker
+				notAfter, err := fetcher.FetchNotAfter(ctx, sampled[i])
+				completed.Add(1)
+				if err != nil {
+					// Fetches cut short by ctx are just unfetched
+					if ctx.Err() == nil {
+						results[i] = result{err: err}
+					}
+					continue
+				}
+				results[i] = result{notAfter: notAfter, fetched: true}
+			}
+		}()
+	}
 
-	for i, removed := range sampled {
+dispatch:
+	for i := range sampled {
 		if i%100 == 0 {
-			log.Printf("fetching cert %d/%d", i, len(sampled))
+			logger.Info("fetching certs", "dispatched", i, "completed", completed.Load(), "sampled", len(sampled))
 		}
-		notAfter, err := fetcher.FetchNotAfter(ctx, removed)
-		if err != nil {
-			return nil, err
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break dispatch
 		}
+	}
+	close(indexes)
+	wg.Wait()
 
-		if prev.ThisUpdate.Before(notAfter) {
+	var earlyRemovals []EarlyRemoval
+	var failed []FetchFailure
+	fetched := 0
+	for i, removed := range sampled {
+		if results[i].err != nil {
+			failed = append(failed, FetchFailure{Serial: removed, Err: results[i].err})
+		}
+		if !results[i].fetched {
+			continue
+		}
+		fetched++
+
+		if prev.ThisUpdate.Before(results[i].notAfter) {
 			// This certificate expired after the previous CRL was issued
 			// All removed CRLs should have been expired in the previous CRL
 			earlyRemovals = append(earlyRemovals, EarlyRemoval{
 				Serial:   removed,
-				NotAfter: notAfter,
+				NotAfter: results[i].notAfter,
 			})
 		}
 	}
 
+	if fetched < len(sampled) {
+		return earlyRemovals, &IncompleteError{Fetched: fetched, Sampled: len(sampled), Failed: failed, Err: context.Cause(ctx)}
+	}
 	return earlyRemovals, nil
 }
@@ -3,6 +3,7 @@ package earlyremoval
 import (
 	"context"
 	"crypto/x509"
+	"errors"
 	"math/big"
 	"math/rand/v2"
 	"testing"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/letsencrypt/crl-monitor/checker/crltest"
 	"github.com/letsencrypt/crl-monitor/checker/expiry/mock"
 	"github.com/letsencrypt/crl-monitor/checker/testdata"
 )
@@ -46,7 +48,7 @@ func TestCheck(t *testing.T) {
 			}},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			early, err := Check(context.Background(), &mockFetcher, 500, tt.prev, tt.crl)
+			early, err := Check(context.Background(), &mockFetcher, 500, 1, tt.prev, tt.crl)
 			require.NoError(t, err)
 			require.Equal(t, tt.expected, early)
 		})
@@ -61,13 +63,107 @@ func TestCheck(t *testing.T) {
 		{expectedError: "old CRL does not precede new CRL", prev: &testdata.CRL2, crl: &testdata.CRL1},
 	} {
 		t.Run(tt.expectedError, func(t *testing.T) {
-			early, err := Check(context.Background(), &mockFetcher, 500, tt.prev, tt.crl)
+			early, err := Check(context.Background(), &mockFetcher, 500, 1, tt.prev, tt.crl)
 			require.ErrorContains(t, err, tt.expectedError)
 			require.Nil(t, early)
 		})
 	}
 }
 
+func TestCheckGeneratedShard(t *testing.T) {
+	ctx := context.Background()
+	issuer, key := crltest.MakeIssuer(t)
+	start := time.Now().Add(-24 * time.Hour)
+	shard := crltest.NewShard(t, issuer, key, "http://c.example/1.crl", start)
+
+	expiring := shard.Revoke(200, start.Add(30*time.Minute), 1)
+	unexpired := shard.Revoke(300, start.Add(90*24*time.Hour), 5)
+	first := shard.Next()
+	second := shard.Next()
+
+	// Expired serials removed after a version is published are fine
+	require.Equal(t, expiring, shard.RemoveExpired())
+	third := shard.Next()
+	early, err := Check(ctx, shard.Fetcher(), 0, 4, second, third)
+	require.NoError(t, err)
+	require.Empty(t, early)
+
+	// But unexpired serials must not be removed
+	shard.Remove(unexpired[10:13]...)
+	fourth := shard.Next()
+	early, err = Check(ctx, shard.Fetcher(), 0, 4, third, fourth)
+	require.NoError(t, err)
+	require.Len(t, early, 3)
+	for i, removal := range early {
+		require.Equal(t, unexpired[10+i], removal.Serial)
+	}
+
+	// Versions out of order can't be compared
+	_, err = Check(ctx, shard.Fetcher(), 0, 4, second, first)
+	require.Error(t, err)
+}
+
+func TestCheckConcurrent(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	start := time.Now().Add(-24 * time.Hour)
+	shard := crltest.NewShard(t, issuer, key, "http://c.example/1.crl", start)
+
+	serials := shard.Revoke(40, start.Add(90*24*time.Hour), 1)
+	prev := shard.Next()
+	shard.Remove(serials...)
+	crl := shard.Next()
+
+	fetcher := shard.Fetcher()
+	fetcher.Delay = 50 * time.Millisecond
+
+	t.Run("all fetched", func(t *testing.T) {
+		// Sequentially, this would take two seconds
+		began := time.Now()
+		early, err := Check(context.Background(), fetcher, 0, 10, prev, crl)
+		require.NoError(t, err)
+		require.Less(t, time.Since(began), time.Second)
+
+		// Results are in the order of the removed serials
+		require.Len(t, early, len(serials))
+		for i, removal := range early {
+			require.Equal(t, serials[i], removal.Serial)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+		defer cancel()
+
+		early, err := Check(ctx, fetcher, 0, 4, prev, crl)
+		var incomplete *IncompleteError
+		require.ErrorAs(t, err, &incomplete)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, len(serials), incomplete.Sampled)
+		require.Less(t, incomplete.Fetched, incomplete.Sampled)
+
+		// The serials which were fetched in time are still reported
+		require.NotEmpty(t, early)
+		require.Len(t, early, incomplete.Fetched)
+	})
+
+	t.Run("fetch failure", func(t *testing.T) {
+		failure := errors.New("certinfo unavailable")
+		fetcher.Fail(serials[20], failure)
+
+		early, err := Check(context.Background(), fetcher, 0, 4, prev, crl)
+		var incomplete *IncompleteError
+		require.ErrorAs(t, err, &incomplete)
+		require.ErrorIs(t, err, failure)
+		require.NoError(t, incomplete.Err)
+
+		// Only the failed serial is left unchecked
+		require.Equal(t, len(serials)-1, incomplete.Fetched)
+		require.Len(t, incomplete.Failed, 1)
+		require.Equal(t, serials[20], incomplete.Failed[0].Serial)
+		require.Len(t, early, len(serials)-1)
+	})
+}
+
 func TestSample(t *testing.T) {
 	require.Empty(t, sample([]int{}, 0))
 	require.Empty(t, sample([]int{}, 999))
@@ -0,0 +1,341 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/crl-monitor/checker/earlyremoval"
+	"github.com/letsencrypt/crl-monitor/checker/mutation"
+	"github.com/letsencrypt/crl-monitor/checker/readded"
+	"github.com/letsencrypt/crl-monitor/notify"
+	"github.com/letsencrypt/crl-monitor/storage"
+)
+
+// Errors returned by Check are either violations, where a CRL is wrong and
+// someone needs to be paged, or transient failures of the infrastructure the
+// checker relies on, which will likely go away on retry.
+
+// violation is implemented by every error type that means a CRL is wrong.
+type violation interface {
+	error
+	violation()
+}
+
+// IsViolation returns true if err, or any error it wraps or joins, is a
+// problem with a CRL rather than a transient failure.
+func IsViolation(err error) bool {
+	var v violation
+	return errors.As(err, &v)
+}
+
+// LintError is a CRL which couldn't be parsed or failed linting.
+type LintError struct {
+	Object string
+	Err    error
+}
+
+func (e *LintError) Error() string {
+	return fmt.Sprintf("crl %s failed linting: %v", e.Object, e.Err)
+}
+
+func (e *LintError) Unwrap() error { return e.Err }
+func (*LintError) violation()      {}
+
+// IDPMismatchError is a CRL whose IssuingDistributionPoint is missing,
+// repeated, or for a different shard than the one it was uploaded as.
+type IDPMismatchError struct {
+	Object string
+	IDPs   []string
+	Err    error
+}
+
+func (e *IDPMismatchError) Error() string {
+	return fmt.Sprintf("crl %s: %v", e.Object, e.Err)
+}
+
+func (e *IDPMismatchError) Unwrap() error { return e.Err }
+func (*IDPMismatchError) violation()      {}
+
+// OrderError is a CRL whose number or thisUpdate doesn't follow the previous
+// version's.
+type OrderError struct {
+	CRLs CRLsSummary
+	Err  error
+}
+
+func (e *OrderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OrderError) Unwrap() error { return e.Err }
+func (*OrderError) violation()      {}
+
+func (e *OrderError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("message", e.Err.Error()), slog.Any("crls", e.CRLs))
+}
+
+// EarlyRemovalError is a CRL which removed serials for certificates that
+// hadn't expired yet.
+type EarlyRemovalError struct {
+	CRLs CRLsSummary
+	// Count is the number of serials removed early, which may be more than
+	// the Sample of them.
+	Count  int
+	Sample []earlyremoval.EarlyRemoval
+}
+
+func (e *EarlyRemovalError) Error() string {
+	return fmt.Sprintf("early removal of %d certificates detected!", e.Count)
+}
+
+func (*EarlyRemovalError) violation() {}
+
+func (e *EarlyRemovalError) LogValue() slog.Value {
+	return sampleLogValue(e.Count, e.Sample, e.CRLs)
+}
+
+// MutationError is a CRL where serials on the previous version changed
+// revocation time or reason.
+type MutationError struct {
+	CRLs   CRLsSummary
+	Count  int
+	Sample []mutation.Mutation
+}
+
+func (e *MutationError) Error() string {
+	return fmt.Sprintf("%d entries changed between versions!", e.Count)
+}
+
+func (*MutationError) violation() {}
+
+func (e *MutationError) LogValue() slog.Value {
+	return sampleLogValue(e.Count, e.Sample, e.CRLs)
+}
+
+// ReaddedError is a CRL which added serials back after they were removed.
+type ReaddedError struct {
+	CRLs   CRLsSummary
+	Count  int
+	Sample []readded.Readded
+}
+
+func (e *ReaddedError) Error() string {
+	return fmt.Sprintf("%d serials re-added after removal detected!", e.Count)
+}
+
+func (*ReaddedError) violation() {}
+
+func (e *ReaddedError) LogValue() slog.Value {
+	return sampleLogValue(e.Count, e.Sample, e.CRLs)
+}
+
+// sampleLogValue logs the count, sample and CRLs of a violation as separate
+// attributes, which Error leaves out to keep it short.
+func sampleLogValue(count int, sample any, crls CRLsSummary) slog.Value {
+	return slog.GroupValue(slog.Int("count", count), slog.Any("sample", sample), slog.Any("crls", crls))
+}
+
+// ChurnedCertError is a certificate revoked by the churner which showed up on
+// a CRL with the wrong reason or revocation time, or on the wrong shard.
+type ChurnedCertError struct {
+	Serial *big.Int
+	IDP    string
+	Err    error
+}
+
+func (e *ChurnedCertError) Error() string {
+	return fmt.Sprintf("cert %x on CRL %q %v", e.Serial, e.IDP, e.Err)
+}
+
+func (e *ChurnedCertError) Unwrap() error { return e.Err }
+func (*ChurnedCertError) violation()      {}
+
+// DuplicateIDPError is more than one shard of an issuer with the same
+// IssuingDistributionPoint.
+type DuplicateIDPError struct {
+	IDP    string
+	Shards []string
+}
+
+func (e *DuplicateIDPError) Error() string {
+	return fmt.Sprintf("%d shards have IssuingDistributionPoint %q: %v", len(e.Shards), e.IDP, e.Shards)
+}
+
+func (*DuplicateIDPError) violation() {}
+
+// DuplicateSerialError is serials found on more than one shard of an issuer.
+type DuplicateSerialError struct {
+	// Issuer is the prefix of the issuer's shards.
+	Issuer string
+	Count  int
+	Sample []DuplicateSerial
+}
+
+func (e *DuplicateSerialError) Error() string {
+	return fmt.Sprintf("%d serials on more than one shard! First %d: %v", e.Count, len(e.Sample), e.Sample)
+}
+
+func (*DuplicateSerialError) violation() {}
+
+// StaleShardError is a shard whose latest version was uploaded longer ago
+// than the Sweeper's threshold, so it has likely stopped being published.
+type StaleShardError struct {
+	Object       string
+	LastModified time.Time
+	Threshold    time.Duration
+}
+
+func (e *StaleShardError) Error() string {
+	return fmt.Sprintf("shard %s was last uploaded at %s, more than %s ago", e.Object, e.LastModified, e.Threshold)
+}
+
+func (*StaleShardError) violation() {}
+
+// ExpiredShardError is a shard whose latest version has a NextUpdate which
+// has passed.
+type ExpiredShardError struct {
+	Object     string
+	Version    string
+	NextUpdate time.Time
+}
+
+func (e *ExpiredShardError) Error() string {
+	return fmt.Sprintf("shard %s version %s has nextUpdate %s, which has passed", e.Object, e.Version, e.NextUpdate)
+}
+
+func (*ExpiredShardError) violation() {}
+
+// ShardCountError is an issuer without the expected number of shards.
+type ShardCountError struct {
+	// Issuer is the prefix of the issuer's shards.
+	Issuer   string
+	Found    int
+	Expected int
+}
+
+func (e *ShardCountError) Error() string {
+	return fmt.Sprintf("found %d shards, expected %d", e.Found, e.Expected)
+}
+
+func (*ShardCountError) violation() {}
+
+// StorageError is a transient failure to read CRLs from storage.
+type StorageError struct {
+	Key storage.Key
+	Err error
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("storage error for %s version %s: %v", e.Key.Object, e.Key.VersionString(), e.Err)
+}
+
+func (e *StorageError) Unwrap() error { return e.Err }
+
+// LookupError is a transient failure to look up certificates, either in the
+// database or from Boulder.
+type LookupError struct {
+	Err error
+}
+
+func (e *LookupError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LookupError) Unwrap() error { return e.Err }
+
+// alertFor describes the violations in err, and the serials and versions
+// they involve, for a notify.Notifier.
+func alertFor(bucket, object string, version *string, err error) notify.Alert {
+	alert := notify.Alert{
+		Source:  "checker",
+		Message: err.Error(),
+		Bucket:  bucket,
+		Shard:   object,
+	}
+
+	var serials []*big.Int
+	var crls []CRLsSummary
+	for _, e := range flatten(err) {
+		switch e := e.(type) {
+		case *OrderError:
+			crls = append(crls, e.CRLs)
+		case *EarlyRemovalError:
+			crls = append(crls, e.CRLs)
+			for _, s := range e.Sample {
+				serials = append(serials, s.Serial)
+			}
+		case *MutationError:
+			crls = append(crls, e.CRLs)
+			for _, s := range e.Sample {
+				serials = append(serials, s.Serial)
+			}
+		case *ReaddedError:
+			crls = append(crls, e.CRLs)
+			for _, s := range e.Sample {
+				serials = append(serials, s.Serial)
+			}
+		case *ChurnedCertError:
+			serials = append(serials, e.Serial)
+		case *DuplicateSerialError:
+			for _, s := range e.Sample {
+				serials = append(serials, s.Serial)
+			}
+		case *ExpiredShardError:
+			if !slices.Contains(alert.Versions, e.Version) {
+				alert.Versions = append(alert.Versions, e.Version)
+			}
+		}
+		if v, ok := e.(violation); ok && alert.Type == "" {
+			alert.Type = strings.TrimPrefix(fmt.Sprintf("%T", v), "*checker.")
+		}
+	}
+
+	for _, summary := range crls {
+		for _, v := range []string{summary.Old.StorageKey.Version, summary.New.StorageKey.Version} {
+			if !slices.Contains(alert.Versions, v) {
+				alert.Versions = append(alert.Versions, v)
+			}
+		}
+	}
+	if len(alert.Versions) == 0 && version != nil {
+		alert.Versions = []string{*version}
+	}
+
+	for _, serial := range serials {
+		alert.Serials = append(alert.Serials, fmt.Sprintf("%036x", serial))
+	}
+	return alert
+}
+
+// errorDetails groups the LogValue of every error in err which has one, keyed
+// by its type, to log alongside the short message from Error.
+func errorDetails(err error) slog.Value {
+	var attrs []slog.Attr
+	for _, e := range flatten(err) {
+		if v, ok := e.(slog.LogValuer); ok {
+			attrs = append(attrs, slog.Any(strings.TrimPrefix(fmt.Sprintf("%T", e), "*checker."), v))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// flatten returns err and every error it wraps or joins, depth first.
+func flatten(err error) []error {
+	errs := []error{err}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			errs = append(errs, flatten(inner)...)
+		}
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			errs = append(errs, flatten(inner)...)
+		}
+	}
+	return errs
+}
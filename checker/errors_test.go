@@ -0,0 +1,79 @@
+package checker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/storage"
+)
+
+func TestIsViolation(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		err       error
+		violation bool
+	}{
+		{"lint", &LintError{Object: "a.crl", Err: errors.New("bad")}, true},
+		{"idp", &IDPMismatchError{Object: "a.crl", Err: errors.New("bad")}, true},
+		{"order", &OrderError{Err: errors.New("bad")}, true},
+		{"early removal", &EarlyRemovalError{Count: 1}, true},
+		{"mutation", &MutationError{Count: 1}, true},
+		{"readded", &ReaddedError{Count: 1}, true},
+		{"churned cert", &ChurnedCertError{Serial: big.NewInt(1), Err: errors.New("bad")}, true},
+		{"duplicate idp", &DuplicateIDPError{IDP: "http://idp/0.crl", Shards: []string{"0.crl", "1.crl"}}, true},
+		{"duplicate serial", &DuplicateSerialError{Count: 1}, true},
+		{"storage", &StorageError{Key: storage.Key{Object: "a.crl"}, Err: errors.New("bad")}, false},
+		{"lookup", &LookupError{Err: errors.New("bad")}, false},
+		{"plain", errors.New("bad"), false},
+		{"wrapped", fmt.Errorf("issuer: %w", &LintError{Err: errors.New("bad")}), true},
+		{"joined", errors.Join(&LookupError{Err: errors.New("bad")}, &ChurnedCertError{Err: errors.New("bad")}), true},
+		{"joined transient", errors.Join(&LookupError{Err: errors.New("bad")}, &StorageError{Err: errors.New("bad")}), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.violation, IsViolation(tt.err))
+		})
+	}
+}
+
+func TestAlertFor(t *testing.T) {
+	err := errors.Join(
+		&ChurnedCertError{Serial: big.NewInt(2), IDP: "http://idp/0.crl", Err: errors.New("has reason code 4, but was revoked with 5")},
+		&LookupError{Err: errors.New("deleting 1 serials from DB")},
+		&ChurnedCertError{Serial: big.NewInt(3), IDP: "http://idp/0.crl", Err: errors.New("has reason code 4, but was revoked with 5")},
+	)
+	version := "v1"
+	alert := alertFor("crl-test", "123/0.crl", &version, err)
+	require.Equal(t, "checker", alert.Source)
+	require.Equal(t, "ChurnedCertError", alert.Type)
+	require.Equal(t, "crl-test", alert.Bucket)
+	require.Equal(t, "123/0.crl", alert.Shard)
+	require.Equal(t, []string{"v1"}, alert.Versions)
+	require.Equal(t, []string{"000000000000000000000000000000000002", "000000000000000000000000000000000003"}, alert.Serials)
+	require.Equal(t, err.Error(), alert.Message)
+}
+
+func TestErrorDetails(t *testing.T) {
+	crls := CRLsSummary{
+		Old: CRLSummary{Number: big.NewInt(1), StorageKey: storageKey{Object: "123/0.crl", Version: "v1"}},
+		New: CRLSummary{Number: big.NewInt(2), StorageKey: storageKey{Object: "123/0.crl", Version: "v2"}},
+	}
+	err := errors.Join(
+		&OrderError{CRLs: crls, Err: errors.New("out of order")},
+		&LookupError{Err: errors.New("timed out")},
+	)
+
+	// The CRLs are logged as attributes rather than in the message
+	require.Equal(t, "out of order\ntimed out", err.Error())
+	details := errorDetails(err).Group()
+	require.Len(t, details, 1)
+	require.Equal(t, "OrderError", details[0].Key)
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("error", "details", errorDetails(err))
+	require.Contains(t, buf.String(), `"details":{"OrderError":{"message":"out of order","crls":{"old":{"bucket":"","object":"123/0.crl","version":"v1","crlNumber":"1"`)
+}
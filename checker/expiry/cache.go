@@ -0,0 +1,124 @@
+package expiry
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/crl-monitor/checker/earlyremoval"
+	"github.com/letsencrypt/crl-monitor/db"
+	"github.com/letsencrypt/crl-monitor/logging"
+)
+
+// Store is a persistent cache of certificate expiry, shared between checker
+// invocations. It is fulfilled by *db.Database with an ExpiryTable.
+type Store interface {
+	GetExpiry(ctx context.Context, serial *big.Int) (time.Time, error)
+	PutExpiry(ctx context.Context, serial *big.Int, notAfter time.Time) error
+}
+
+// CachingFetcher is an earlyremoval.Fetcher which remembers the NotAfter of
+// serials fetched by another Fetcher, since it never changes. Recently used
+// serials are kept in memory, and if there's a Store, every serial fetched is
+// stored there too. Use NewCachingFetcher to obtain one.
+type CachingFetcher struct {
+	fetcher earlyremoval.Fetcher
+	store   Store
+	size    int
+
+	mu sync.Mutex
+	// recent holds the cached serials, most recently used first, and entries
+	// indexes its elements by serial
+	recent  *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	serial   string
+	notAfter time.Time
+}
+
+// NewCachingFetcher returns a CachingFetcher keeping up to size serials in
+// memory. The store is optional, and may be nil.
+func NewCachingFetcher(fetcher earlyremoval.Fetcher, size int, store Store) *CachingFetcher {
+	return &CachingFetcher{
+		fetcher: fetcher,
+		store:   store,
+		size:    size,
+		recent:  list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// FetchNotAfter returns the cached NotAfter for serial if there is one, or
+// else fetches and caches it. Failures of the Store are logged rather than
+// returned, as the fetcher can still be asked.
+func (cf *CachingFetcher) FetchNotAfter(ctx context.Context, serial *big.Int) (time.Time, error) {
+	if notAfter, ok := cf.get(serial); ok {
+		return notAfter, nil
+	}
+
+	if cf.store != nil {
+		notAfter, err := cf.store.GetExpiry(ctx, serial)
+		if err == nil {
+			cf.add(serial, notAfter)
+			return notAfter, nil
+		}
+		if !errors.Is(err, db.ErrNoExpiry) {
+			logging.FromContext(ctx).Warn("error getting cached certificate expiry", "serial", serial.Text(16), "error", err)
+		}
+	}
+
+	notAfter, err := cf.fetcher.FetchNotAfter(ctx, serial)
+	if err != nil {
+		return time.Time{}, err
+	}
+	cf.add(serial, notAfter)
+
+	if cf.store != nil {
+		err = cf.store.PutExpiry(ctx, serial, notAfter)
+		if err != nil {
+			logging.FromContext(ctx).Warn("error caching certificate expiry", "serial", serial.Text(16), "error", err)
+		}
+	}
+	return notAfter, nil
+}
+
+func (cf *CachingFetcher) get(serial *big.Int) (time.Time, bool) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	element, ok := cf.entries[serial.String()]
+	if !ok {
+		return time.Time{}, false
+	}
+	cf.recent.MoveToFront(element)
+	return element.Value.(*cacheEntry).notAfter, true
+}
+
+// add caches a serial in memory, evicting the least recently used if the
+// cache is full.
+func (cf *CachingFetcher) add(serial *big.Int, notAfter time.Time) {
+	if cf.size <= 0 {
+		return
+	}
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	key := serial.String()
+	if element, ok := cf.entries[key]; ok {
+		cf.recent.MoveToFront(element)
+		return
+	}
+	cf.entries[key] = cf.recent.PushFront(&cacheEntry{serial: key, notAfter: notAfter})
+
+	if cf.recent.Len() > cf.size {
+		oldest := cf.recent.Back()
+		cf.recent.Remove(oldest)
+		delete(cf.entries, oldest.Value.(*cacheEntry).serial)
+	}
+}
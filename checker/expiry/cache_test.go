@@ -0,0 +1,83 @@
+package expiry
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/checker/expiry/mock"
+	dbmock "github.com/letsencrypt/crl-monitor/db/mock"
+)
+
+// countingFetcher counts the serials it is asked to fetch
+type countingFetcher struct {
+	mock.Fetcher
+	fetched []int64
+}
+
+func (f *countingFetcher) FetchNotAfter(ctx context.Context, serial *big.Int) (time.Time, error) {
+	f.fetched = append(f.fetched, serial.Int64())
+	return f.Fetcher.FetchNotAfter(ctx, serial)
+}
+
+func TestCachingFetcher(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	inner := &countingFetcher{}
+	for serial := range int64(4) {
+		inner.AddTestData(big.NewInt(serial), now.Add(time.Duration(serial)*time.Hour))
+	}
+
+	fetch := func(fetcher *CachingFetcher, serial int64) {
+		t.Helper()
+		notAfter, err := fetcher.FetchNotAfter(ctx, big.NewInt(serial))
+		require.NoError(t, err)
+		require.True(t, now.Add(time.Duration(serial)*time.Hour).Equal(notAfter), "serial %d: got %s", serial, notAfter)
+	}
+
+	t.Run("lru", func(t *testing.T) {
+		inner.fetched = nil
+		fetcher := NewCachingFetcher(inner, 2, nil)
+		fetch(fetcher, 1)
+		fetch(fetcher, 2)
+		fetch(fetcher, 1)
+		// Serial 2 is the least recently used, so fetching 3 evicts it
+		fetch(fetcher, 3)
+		fetch(fetcher, 1)
+		fetch(fetcher, 2)
+		require.Equal(t, []int64{1, 2, 3, 2}, inner.fetched)
+	})
+
+	t.Run("store", func(t *testing.T) {
+		inner.fetched = nil
+		database := dbmock.NewMockedDB(t)
+		fetch(NewCachingFetcher(inner, 10, database), 1)
+
+		// A fresh container, with nothing in memory, uses the stored expiry
+		fetcher := NewCachingFetcher(inner, 10, database)
+		fetch(fetcher, 1)
+		fetch(fetcher, 2)
+		require.Equal(t, []int64{1, 2}, inner.fetched)
+
+		// And with no memory at all, it still needn't fetch again
+		fetcher = NewCachingFetcher(inner, 0, database)
+		fetch(fetcher, 1)
+		fetch(fetcher, 2)
+		require.Equal(t, []int64{1, 2}, inner.fetched)
+	})
+
+	t.Run("errors aren't cached", func(t *testing.T) {
+		inner.fetched = nil
+		fetcher := NewCachingFetcher(inner, 10, dbmock.NewMockedDB(t))
+		_, err := fetcher.FetchNotAfter(ctx, big.NewInt(7))
+		require.ErrorContains(t, err, "unknown serial 7")
+
+		inner.AddTestData(big.NewInt(7), now.Add(7*time.Hour))
+		fetch(fetcher, 7)
+		require.Equal(t, []int64{7, 7}, inner.fetched)
+	})
+}
@@ -0,0 +1,61 @@
+package mutation
+
+import (
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/mholt/acmez/v3/acme"
+)
+
+// Mutation is a serial whose entry changed between two versions of a CRL.
+type Mutation struct {
+	Serial             *big.Int
+	PrevRevocationTime time.Time
+	RevocationTime     time.Time
+	PrevReasonCode     int
+	ReasonCode         int
+}
+
+func (m Mutation) String() string {
+	return fmt.Sprintf("%036x: revocation time %s -> %s, reason %d -> %d",
+		m.Serial, m.PrevRevocationTime.UTC().Format(time.RFC3339), m.RevocationTime.UTC().Format(time.RFC3339), m.PrevReasonCode, m.ReasonCode)
+}
+
+// Check compares the entries for serials present on both prev and crl, and
+// returns any whose revocation time or reason changed. Once a serial is on a
+// CRL its entry must stay fixed, except that the reason may be updated to
+// keyCompromise, keeping the original revocation time.
+func Check(prev *x509.RevocationList, crl *x509.RevocationList) []Mutation {
+	prevEntries := make(map[string]x509.RevocationListEntry, len(prev.RevokedCertificateEntries))
+	for _, entry := range prev.RevokedCertificateEntries {
+		prevEntries[entry.SerialNumber.String()] = entry
+	}
+
+	var mutations []Mutation
+	for _, entry := range crl.RevokedCertificateEntries {
+		prevEntry, ok := prevEntries[entry.SerialNumber.String()]
+		if !ok {
+			continue
+		}
+
+		sameTime := entry.RevocationTime.Equal(prevEntry.RevocationTime)
+		if sameTime && entry.ReasonCode == prevEntry.ReasonCode {
+			continue
+		}
+		if sameTime && entry.ReasonCode == acme.ReasonKeyCompromise {
+			// Updating the reason to keyCompromise is allowed
+			continue
+		}
+
+		mutations = append(mutations, Mutation{
+			Serial:             entry.SerialNumber,
+			PrevRevocationTime: prevEntry.RevocationTime,
+			RevocationTime:     entry.RevocationTime,
+			PrevReasonCode:     prevEntry.ReasonCode,
+			ReasonCode:         entry.ReasonCode,
+		})
+	}
+	return mutations
+}
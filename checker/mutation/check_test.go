@@ -0,0 +1,86 @@
+package mutation
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	later := now.Add(time.Hour)
+
+	prev := &x509.RevocationList{
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(1), RevocationTime: now},
+			{SerialNumber: big.NewInt(2), RevocationTime: now, ReasonCode: 5},
+			{SerialNumber: big.NewInt(3), RevocationTime: now, ReasonCode: 1},
+		},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		entries  []x509.RevocationListEntry
+		expected []Mutation
+	}{
+		{name: "unchanged", entries: prev.RevokedCertificateEntries},
+		{
+			name: "additions and removals",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(2), RevocationTime: now, ReasonCode: 5},
+				{SerialNumber: big.NewInt(4), RevocationTime: later, ReasonCode: 4},
+			},
+		},
+		{
+			name: "reason updated to keyCompromise",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(1), RevocationTime: now, ReasonCode: 1},
+				{SerialNumber: big.NewInt(2), RevocationTime: now, ReasonCode: 1},
+			},
+		},
+		{
+			name: "reason changed",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(2), RevocationTime: now, ReasonCode: 4},
+			},
+			expected: []Mutation{
+				{Serial: big.NewInt(2), PrevRevocationTime: now, RevocationTime: now, PrevReasonCode: 5, ReasonCode: 4},
+			},
+		},
+		{
+			name: "reason changed from keyCompromise",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(3), RevocationTime: now},
+			},
+			expected: []Mutation{
+				{Serial: big.NewInt(3), PrevRevocationTime: now, RevocationTime: now, PrevReasonCode: 1, ReasonCode: 0},
+			},
+		},
+		{
+			name: "time changed",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(1), RevocationTime: later},
+			},
+			expected: []Mutation{
+				{Serial: big.NewInt(1), PrevRevocationTime: now, RevocationTime: later},
+			},
+		},
+		{
+			name: "time changed with keyCompromise",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(2), RevocationTime: later, ReasonCode: 1},
+			},
+			expected: []Mutation{
+				{Serial: big.NewInt(2), PrevRevocationTime: now, RevocationTime: later, PrevReasonCode: 5, ReasonCode: 1},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mutations := Check(prev, &x509.RevocationList{RevokedCertificateEntries: tt.entries})
+			require.Equal(t, tt.expected, mutations)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package readded
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+
+	"github.com/letsencrypt/boulder/crl/checker"
+)
+
+// Version is a single version of a CRL shard.
+type Version struct {
+	ID  string
+	CRL *x509.RevocationList
+}
+
+// Readded is a serial that was removed from a shard and then added back.
+type Readded struct {
+	Serial *big.Int
+	// RemovedVersion is the first version the serial was missing from.
+	RemovedVersion string
+	// ReaddedVersion is the version the serial reappeared in.
+	ReaddedVersion string
+}
+
+// Check takes consecutive versions of a shard, oldest first, and returns any
+// serials added in the newest version that were removed in an earlier one.
+// Once a serial is removed from a CRL, it should never come back, so this
+// indicates sharding instability or a bug in the CRL updater.
+func Check(versions []Version) ([]Readded, error) {
+	removed := make(map[string]string)
+	var readded []Readded
+	for i := 1; i < len(versions); i++ {
+		prev, crl := versions[i-1], versions[i]
+		// As in earlyremoval.Check, bit-for-bit identical duplicates are allowed
+		if len(crl.CRL.Raw) > 0 && bytes.Equal(prev.CRL.Raw, crl.CRL.Raw) {
+			continue
+		}
+
+		diff, err := checker.Diff(prev.CRL, crl.CRL)
+		if err != nil {
+			return nil, fmt.Errorf("comparing versions %s and %s: %w", prev.ID, crl.ID, err)
+		}
+
+		if i == len(versions)-1 {
+			for _, added := range diff.Added {
+				if removedVersion, ok := removed[added.String()]; ok {
+					readded = append(readded, Readded{
+						Serial:         added,
+						RemovedVersion: removedVersion,
+						ReaddedVersion: crl.ID,
+					})
+				}
+			}
+		}
+
+		for _, serial := range diff.Removed {
+			removed[serial.String()] = crl.ID
+		}
+	}
+
+	return readded, nil
+}
@@ -0,0 +1,69 @@
+package readded
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func makeVersion(id string, number int64, serials ...int64) Version {
+	crl := &x509.RevocationList{
+		ThisUpdate: time.Now().Add(time.Duration(number) * time.Hour),
+		Number:     big.NewInt(number),
+		Raw:        []byte(id),
+	}
+	for _, serial := range serials {
+		crl.RevokedCertificateEntries = append(crl.RevokedCertificateEntries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial)})
+	}
+	return Version{ID: id, CRL: crl}
+}
+
+func TestCheck(t *testing.T) {
+	v1 := makeVersion("v1", 1, 1, 2, 3)
+	v2 := makeVersion("v2", 2, 2, 3)
+	v3 := makeVersion("v3", 3, 3)
+	v4 := makeVersion("v4", 4, 1, 3, 4)
+	v4dup := Version{ID: "v4dup", CRL: v4.CRL}
+	v5 := makeVersion("v5", 5, 2, 3, 4)
+
+	for _, tt := range []struct {
+		name     string
+		versions []Version
+		expected []Readded
+	}{
+		{name: "no versions"},
+		{name: "one version", versions: []Version{v1}},
+		{name: "only removals", versions: []Version{v1, v2, v3}},
+		{name: "new serial added", versions: []Version{v3, v4}},
+		{
+			name:     "readded",
+			versions: []Version{v1, v2, v3, v4},
+			expected: []Readded{{Serial: big.NewInt(1), RemovedVersion: "v2", ReaddedVersion: "v4"}},
+		},
+		{
+			name:     "readded before duplicate is only reported once",
+			versions: []Version{v1, v2, v3, v4, v4dup},
+		},
+		{
+			name:     "removal outside the window",
+			versions: []Version{v2, v3, v4},
+		},
+		{
+			name:     "readded in the next version",
+			versions: []Version{v2, v3, v5},
+			expected: []Readded{{Serial: big.NewInt(2), RemovedVersion: "v3", ReaddedVersion: "v5"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			readded, err := Check(tt.versions)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, readded)
+		})
+	}
+
+	_, err := Check([]Version{v2, v1})
+	require.ErrorContains(t, err, "comparing versions v2 and v1")
+}
@@ -0,0 +1,98 @@
+package checker
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+
+	"github.com/mholt/acmez/v3/acme"
+
+	"github.com/letsencrypt/crl-monitor/db"
+)
+
+// allowedReasonCodes are the CRLReasons the Baseline Requirements (section
+// 7.2.2) permit on CRLs for subscriber certificates. Unspecified is only
+// permitted by leaving out the reasonCode extension entirely.
+var allowedReasonCodes = map[int]bool{
+	acme.ReasonKeyCompromise:        true,
+	acme.ReasonAffiliationChanged:   true,
+	acme.ReasonSuperseded:           true,
+	acme.ReasonCessationOfOperation: true,
+	acme.ReasonPrivilegeWithdrawn:   true,
+}
+
+var oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// badReason is a CRL entry with a reason code that isn't allowed.
+type badReason struct {
+	Serial     *big.Int
+	ReasonCode int
+}
+
+func (br badReason) String() string {
+	return fmt.Sprintf("%036x:%d", br.Serial, br.ReasonCode)
+}
+
+// checkReasonCodes errors if any entries on the CRL have a reason code which
+// isn't allowed for subscriber certificates.
+func checkReasonCodes(crl *x509.RevocationList) error {
+	var bad []badReason
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.ReasonCode == acme.ReasonUnspecified && !hasReasonCodeExtension(entry) {
+			continue
+		}
+		if !allowedReasonCodes[entry.ReasonCode] {
+			bad = append(bad, badReason{Serial: entry.SerialNumber, ReasonCode: entry.ReasonCode})
+		}
+	}
+
+	if len(bad) != 0 {
+		sample := bad
+		if len(sample) > 50 {
+			sample = sample[:50]
+		}
+		return fmt.Errorf("%d entries with disallowed reason codes! First %d (serial:reason): %v", len(bad), len(sample), sample)
+	}
+	return nil
+}
+
+// hasReasonCodeExtension distinguishes an entry with an explicitly encoded
+// unspecified reason, which isn't allowed, from one without a reason at all.
+func hasReasonCodeExtension(entry x509.RevocationListEntry) bool {
+	for _, ext := range entry.Extensions {
+		if ext.Id.Equal(oidExtensionReasonCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedReasonCode is the reason code the CRL entry for a churned cert
+// should have, given how it was revoked.
+func expectedReasonCode(metadata db.CertMetadata) int {
+	switch metadata.RevocationMethod {
+	case db.RevokedByCertKey:
+		return acme.ReasonKeyCompromise
+	case db.RevokedByAccount:
+		return metadata.ReasonCode
+	default:
+		// Before the method was recorded, the churner always revoked with
+		// cessationOfOperation
+		return acme.ReasonCessationOfOperation
+	}
+}
+
+// checkChurnedReason errors if the CRL entry for a churned cert doesn't have
+// the reason code it was revoked with.
+func checkChurnedReason(entry x509.RevocationListEntry, metadata db.CertMetadata) error {
+	expected := expectedReasonCode(metadata)
+	if entry.ReasonCode != expected {
+		if metadata.RevocationMethod == "" {
+			return fmt.Errorf("has reason code %d, but was revoked with %d", entry.ReasonCode, expected)
+		}
+		return fmt.Errorf("has reason code %d, but should have %d after revocation by %s requesting %d",
+			entry.ReasonCode, expected, metadata.RevocationMethod, metadata.ReasonCode)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package checker
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/checker/crltest"
+	"github.com/letsencrypt/crl-monitor/checker/testdata"
+	"github.com/letsencrypt/crl-monitor/db"
+	dbmock "github.com/letsencrypt/crl-monitor/db/mock"
+	"github.com/letsencrypt/crl-monitor/storage"
+	storagemock "github.com/letsencrypt/crl-monitor/storage/mock"
+)
+
+func TestCheckReasonCodes(t *testing.T) {
+	// An explicitly encoded unspecified (0) reason
+	unspecified := pkix.Extension{Id: oidExtensionReasonCode, Value: []byte{0x0a, 0x01, 0x00}}
+
+	for _, tt := range []struct {
+		name        string
+		entries     []x509.RevocationListEntry
+		expectedErr string
+	}{
+		{name: "no entries"},
+		{
+			name: "allowed reasons",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(1)},
+				{SerialNumber: big.NewInt(2), ReasonCode: 1},
+				{SerialNumber: big.NewInt(3), ReasonCode: 3},
+				{SerialNumber: big.NewInt(4), ReasonCode: 4},
+				{SerialNumber: big.NewInt(5), ReasonCode: 5},
+				{SerialNumber: big.NewInt(6), ReasonCode: 9},
+			},
+		},
+		{
+			name: "certificateHold",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(1), ReasonCode: 5},
+				{SerialNumber: big.NewInt(2), ReasonCode: 6},
+			},
+			expectedErr: "1 entries with disallowed reason codes! First 1 (serial:reason): [000000000000000000000000000000000002:6]",
+		},
+		{
+			name: "cACompromise and removeFromCRL",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(1), ReasonCode: 2},
+				{SerialNumber: big.NewInt(2), ReasonCode: 8},
+			},
+			expectedErr: "2 entries with disallowed reason codes!",
+		},
+		{
+			name: "explicit unspecified",
+			entries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(1), Extensions: []pkix.Extension{unspecified}},
+			},
+			expectedErr: "[000000000000000000000000000000000001:0]",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReasonCodes(&x509.RevocationList{RevokedCertificateEntries: tt.entries})
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestLookForSeenCertsReasonCode(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	idpURL := "http://idp/reasons.crl"
+
+	crlDER := crltest.MakeCRL(t, &x509.RevocationList{
+		ThisUpdate: testdata.Now,
+		NextUpdate: testdata.Now.Add(24 * time.Hour),
+		Number:     big.NewInt(1),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(1), RevocationTime: testdata.Now, ReasonCode: testdata.CessationOfOperation},
+			{SerialNumber: big.NewInt(2), RevocationTime: testdata.Now, ReasonCode: 4},
+		},
+	}, idpURL, issuer, key)
+	crl, err := x509.ParseRevocationList(crlDER)
+	require.NoError(t, err)
+
+	bucket := "crl-test"
+	data := map[string][]storagemock.MockObject{
+		"reasons.crl": {{VersionID: "v1", Data: crlDER, LastModified: testdata.Now}},
+	}
+	checker := New(Config{
+		DB:                      dbmock.NewMockedDB(t),
+		Storage:                 storagemock.New(t, bucket, data),
+		FetchConcurrency:        1,
+		AgeLimit:                24 * time.Hour,
+		RevocationTimeTolerance: time.Minute,
+	})
+	ctx := context.Background()
+	for _, serial := range []int64{1, 2} {
+		require.NoError(t, checker.db.AddCert(ctx, &x509.Certificate{SerialNumber: big.NewInt(serial), CRLDistributionPoints: []string{idpURL}}, "", db.Revocation{Time: testdata.Now}))
+	}
+
+	err = checker.lookForSeenCerts(ctx, storage.Key{Bucket: bucket, Object: "reasons.crl", Version: aws.String("v1")}, crl)
+	require.ErrorContains(t, err, "cert 2 on CRL \"http://idp/reasons.crl\" has reason code 4, but was revoked with 5")
+
+	// Both certs were seen, even though one had the wrong reason
+	unseenCerts := dbmock.Certs(t, checker.db)
+	require.Empty(t, unseenCerts)
+}
+
+func TestCheckChurnedReason(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		reasonCode  int
+		metadata    db.CertMetadata
+		expectedErr string
+	}{
+		{"legacy", testdata.CessationOfOperation, db.CertMetadata{}, ""},
+		{"legacy wrong reason", 4, db.CertMetadata{}, "has reason code 4, but was revoked with 5"},
+		{"account", 4, db.CertMetadata{RevocationMethod: db.RevokedByAccount, ReasonCode: 4}, ""},
+		{"account unspecified", 0, db.CertMetadata{RevocationMethod: db.RevokedByAccount, ReasonCode: 0}, ""},
+		{"account wrong reason", 5, db.CertMetadata{RevocationMethod: db.RevokedByAccount, ReasonCode: 4},
+			"has reason code 5, but should have 4 after revocation by account requesting 4"},
+		{"cert key", 1, db.CertMetadata{RevocationMethod: db.RevokedByCertKey, ReasonCode: 5}, ""},
+		{"cert key wrong reason", 5, db.CertMetadata{RevocationMethod: db.RevokedByCertKey, ReasonCode: 5},
+			"has reason code 5, but should have 1 after revocation by certkey requesting 5"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkChurnedReason(x509.RevocationListEntry{SerialNumber: big.NewInt(1), ReasonCode: tt.reasonCode}, tt.metadata)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package checker
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/letsencrypt/crl-monitor/checker/readded"
+	"github.com/letsencrypt/crl-monitor/storage"
+)
+
+// Violation is a problem Replay found with one version of a shard, or with the
+// change to it from the version before. Replay also reports transient errors,
+// which kept a check of a version from completing, as a Violation whose Err
+// isn't one according to IsViolation.
+type Violation struct {
+	Object string
+	// PrevVersion is empty if the problem is with Version by itself.
+	PrevVersion string
+	Version     string
+	Err         error
+}
+
+func (v Violation) String() string {
+	if v.PrevVersion == "" {
+		return fmt.Sprintf("%s version %s: %v", v.Object, v.Version, v.Err)
+	}
+	return fmt.Sprintf("%s version %s -> %s: %v", v.Object, v.PrevVersion, v.Version, v.Err)
+}
+
+// Replay runs the checks Check does over every consecutive pair of versions of
+// a shard, which must be given oldest first. Unlike Check, it keeps going after
+// a violation and returns all of them, and it doesn't mark any certificates as
+// seen in the database. Early removal is only checked if the Checker has a
+// Fetcher. A version which can't be parsed is skipped, and the next compared
+// with the last version which could be. Transient errors, such as failures to
+// look up certificates, are returned separately from the violations. An error
+// is only returned if a version couldn't be fetched.
+func (c *Checker) Replay(ctx context.Context, bucket, object string, versions []string) ([]Violation, []Violation, error) {
+	var violations, transient []Violation
+	var prev *x509.RevocationList
+	var prevKey storage.Key
+	// earlier holds the in-order versions before prev, oldest first, for
+	// checking re-added serials
+	var earlier []readded.Version
+	for _, version := range versions {
+		key := storage.Key{
+			Bucket:  bucket,
+			Object:  object,
+			Version: &version,
+		}
+		crlDER, _, err := c.storage.Fetch(ctx, key)
+		if err != nil {
+			return violations, transient, err
+		}
+
+		violation := func(prevVersion string, err error) {
+			v := Violation{Object: object, PrevVersion: prevVersion, Version: version, Err: err}
+			if IsViolation(err) {
+				violations = append(violations, v)
+			} else {
+				transient = append(transient, v)
+			}
+		}
+
+		crl, err := x509.ParseRevocationList(crlDER)
+		if err != nil {
+			violation("", &LintError{Object: object, Err: fmt.Errorf("parsing crl: %v", err)})
+			continue
+		}
+
+		err = c.lint(ctx, object, crl)
+		if err != nil {
+			violation("", err)
+		}
+
+		if prev != nil {
+			err = c.compare(ctx, prev, prevKey, crl, key, func() ([]readded.Version, error) {
+				return earlier, nil
+			})
+			// Report each problem with the pair separately
+			if err != nil {
+				for _, e := range unjoin(err) {
+					violation(prevKey.VersionString(), e)
+				}
+			}
+
+			var orderErr *OrderError
+			if errors.As(err, &orderErr) {
+				// Versions out of order can't be compared for re-added serials
+				earlier = nil
+			} else {
+				earlier = append(earlier, readded.Version{ID: prevKey.VersionString(), CRL: prev})
+				if len(earlier) > c.readdWindow-1 {
+					earlier = earlier[len(earlier)-max(c.readdWindow-1, 0):]
+				}
+			}
+		}
+
+		prev = crl
+		prevKey = key
+	}
+	return violations, transient, nil
+}
+
+// unjoin returns the errors joined together in err, however deeply, or err
+// alone if it isn't joined. Errors wrapped in other errors aren't split up.
+func unjoin(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, unjoin(e)...)
+	}
+	return errs
+}
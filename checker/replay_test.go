@@ -0,0 +1,124 @@
+package checker
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/checker/crltest"
+	expirymock "github.com/letsencrypt/crl-monitor/checker/expiry/mock"
+	"github.com/letsencrypt/crl-monitor/checker/testdata"
+	storagemock "github.com/letsencrypt/crl-monitor/storage/mock"
+)
+
+func TestReplay(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	object := fmt.Sprintf("%s/3.crl", nameID(issuer))
+	idpURL := fmt.Sprintf("http://idp/%s", object)
+
+	fetcher := expirymock.Fetcher{}
+	// Serial 1 expires before v2, serials 2 and 3 long after everything
+	fetcher.AddTestData(big.NewInt(1), testdata.Now.Add(30*time.Minute))
+	fetcher.AddTestData(big.NewInt(2), testdata.Now.Add(90*24*time.Hour))
+	fetcher.AddTestData(big.NewInt(3), testdata.Now.Add(90*24*time.Hour))
+
+	makeCRL := func(number int64, thisUpdate time.Duration, serials ...int64) []byte {
+		var entries []x509.RevocationListEntry
+		for _, serial := range serials {
+			entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: testdata.Now})
+		}
+		return crltest.MakeCRL(t, &x509.RevocationList{
+			ThisUpdate:                testdata.Now.Add(thisUpdate),
+			NextUpdate:                testdata.Now.Add(thisUpdate + 24*time.Hour),
+			Number:                    big.NewInt(number),
+			RevokedCertificateEntries: entries,
+		}, idpURL, issuer, key)
+	}
+
+	v2 := makeCRL(2, 2*time.Hour, 2, 3)
+	bucket := "crl-test"
+	// Newest first, as S3 lists them
+	data := map[string][]storagemock.MockObject{
+		object: {
+			{VersionID: "v7", Data: makeCRL(5, 6*time.Hour)},
+			{VersionID: "v6", Data: []byte{0x30, 0x00}},
+			{VersionID: "v5", Data: makeCRL(4, 5*time.Hour, 3)},
+			{VersionID: "v4", Data: makeCRL(2, 4*time.Hour, 2, 3)},
+			{VersionID: "v3", Data: v2},
+			{VersionID: "v2", Data: v2},
+			{VersionID: "v1", Data: makeCRL(1, time.Hour, 1, 2, 3)},
+		},
+	}
+
+	checker := New(Config{
+		Storage:          storagemock.New(t, bucket, data),
+		Fetcher:          &fetcher,
+		FetchConcurrency: 1,
+		AgeLimit:         24 * time.Hour,
+		Issuers:          []*x509.Certificate{issuer},
+		ReaddWindow:      4,
+	})
+
+	violations, transient, err := checker.Replay(context.Background(), bucket, object, []string{"v1", "v2", "v3", "v4", "v5", "v6", "v7"})
+	require.NoError(t, err)
+	require.Empty(t, transient)
+	require.Len(t, violations, 4)
+
+	// v2 correctly removes serial 1 and v3 is an identical upload of v2, so
+	// the first problem is v4 reusing CRL number 2.
+	require.Equal(t, "v3", violations[0].PrevVersion)
+	require.Equal(t, "v4", violations[0].Version)
+	require.ErrorContains(t, violations[0].Err, "doesn't follow previous number 2")
+
+	require.Equal(t, "v4", violations[1].PrevVersion)
+	require.Equal(t, "v5", violations[1].Version)
+	require.ErrorContains(t, violations[1].Err, "early removal of 1 certificates detected!")
+
+	require.Equal(t, "", violations[2].PrevVersion)
+	require.Equal(t, "v6", violations[2].Version)
+	require.ErrorContains(t, violations[2].Err, "parsing crl")
+	require.Contains(t, violations[2].String(), "version v6")
+
+	// v6 can't be parsed, so v7 is compared with v5, and removes serial 3 early
+	require.Equal(t, "v5", violations[3].PrevVersion)
+	require.Equal(t, "v7", violations[3].Version)
+	require.ErrorContains(t, violations[3].Err, "early removal of 1 certificates detected!")
+}
+
+func TestReplayTransientErrors(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	object := fmt.Sprintf("%s/4.crl", nameID(issuer))
+	shard := crltest.NewShard(t, issuer, key, fmt.Sprintf("http://idp/%s", object), time.Now().Add(-2*time.Hour))
+	shard.Next()
+	serials := shard.Revoke(2, time.Now().Add(90*24*time.Hour), 5)
+	shard.Next()
+	shard.Remove(serials[0])
+	shard.Next()
+
+	fetcher := shard.Fetcher()
+	fetcher.Fail(serials[0], errors.New("boulder is down"))
+	bucket := "crl-test"
+	checker := New(Config{
+		Storage:          storagemock.New(t, bucket, map[string][]storagemock.MockObject{object: shard.MockObjects()}),
+		Fetcher:          fetcher,
+		FetchConcurrency: 1,
+		AgeLimit:         24 * time.Hour,
+		Issuers:          []*x509.Certificate{issuer},
+	})
+
+	violations, transient, err := checker.Replay(context.Background(), bucket, object, []string{"v1", "v2", "v3"})
+	require.NoError(t, err)
+	// Failing to look up a certificate isn't a problem with the CRL
+	require.Empty(t, violations)
+	require.Len(t, transient, 1)
+	require.Equal(t, "v2", transient[0].PrevVersion)
+	require.Equal(t, "v3", transient[0].Version)
+	require.False(t, IsViolation(transient[0].Err))
+	require.ErrorContains(t, transient[0].Err, "boulder is down")
+}
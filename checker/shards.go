@@ -0,0 +1,122 @@
+package checker
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/letsencrypt/crl-monitor/logging"
+	"github.com/letsencrypt/crl-monitor/storage"
+)
+
+// DuplicateSerial is a serial found on more than one shard of an issuer.
+type DuplicateSerial struct {
+	Serial *big.Int
+	// Shards are the objects and versions the serial was found on.
+	Shards []string
+}
+
+func (ds DuplicateSerial) String() string {
+	return fmt.Sprintf("%036x:%v", ds.Serial, ds.Shards)
+}
+
+// CheckAllShards runs CheckShards for the prefix of each issuer the Checker
+// was created with, and returns all of their errors.
+func (c *Checker) CheckAllShards(ctx context.Context, bucket string) error {
+	var errs []error
+	for _, prefix := range slices.Sorted(maps.Keys(c.issuers)) {
+		err := c.CheckShards(ctx, bucket, prefix)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("issuer %s (CN=%s): %w", prefix, c.issuers[prefix].Subject.CommonName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckShards loads the current version of every shard under an issuer's
+// prefix. It errors if any serial appears on more than one shard, if a shard's
+// IssuingDistributionPoint doesn't match its object name, or if more than one
+// shard has the same IssuingDistributionPoint.
+//
+// Which shard a serial belongs on depends on its certificate, which isn't on
+// the CRL, so a serial on only the wrong shard isn't caught here. Check catches
+// that for certificates issued by the churner, which are in the database.
+func (c *Checker) CheckShards(ctx context.Context, bucket, prefix string) error {
+	objects, err := c.storage.List(ctx, bucket, prefix+"/")
+	if err != nil {
+		return &StorageError{Key: storage.Key{Bucket: bucket, Object: prefix + "/"}, Err: err}
+	}
+
+	// shards maps each serial to the shards it was found on, and idps maps
+	// each IssuingDistributionPoint to the shards that have it.
+	shards := make(map[string][]string)
+	idps := make(map[string][]string)
+	serials := make(map[string]*big.Int)
+	var errs []error
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, ".crl") {
+			continue
+		}
+
+		key := storage.Key{Bucket: bucket, Object: object.Key}
+		crlDER, version, err := c.storage.Fetch(ctx, key)
+		if err != nil {
+			// The other shards can still be checked
+			errs = append(errs, &StorageError{Key: key, Err: err})
+			continue
+		}
+		shard := fmt.Sprintf("%s version %s", object.Key, version)
+
+		crl, err := x509.ParseRevocationList(crlDER)
+		if err != nil {
+			errs = append(errs, &LintError{Object: shard, Err: fmt.Errorf("parsing crl: %v", err)})
+			continue
+		}
+
+		idp, err := getIDP(crl)
+		if err != nil {
+			errs = append(errs, &IDPMismatchError{Object: shard, Err: err})
+		} else {
+			// Shards are uploaded with the same filename as they're served from
+			if path.Base(idp) != path.Base(object.Key) {
+				errs = append(errs, &IDPMismatchError{Object: shard, IDPs: []string{idp}, Err: fmt.Errorf("has IssuingDistributionPoint %q, which is for a different shard", idp)})
+			}
+			idps[idp] = append(idps[idp], shard)
+		}
+
+		for _, entry := range crl.RevokedCertificateEntries {
+			serial := entry.SerialNumber.String()
+			serials[serial] = entry.SerialNumber
+			shards[serial] = append(shards[serial], shard)
+		}
+	}
+	logging.FromContext(ctx).Info("checked shards", logging.Bucket, bucket, logging.Issuer, prefix, "shards", len(objects), "serials", len(serials))
+
+	for _, idp := range slices.Sorted(maps.Keys(idps)) {
+		if len(idps[idp]) > 1 {
+			errs = append(errs, &DuplicateIDPError{IDP: idp, Shards: idps[idp]})
+		}
+	}
+
+	var duplicates []DuplicateSerial
+	for _, serial := range slices.Sorted(maps.Keys(shards)) {
+		if len(shards[serial]) > 1 {
+			duplicates = append(duplicates, DuplicateSerial{Serial: serials[serial], Shards: shards[serial]})
+		}
+	}
+	if len(duplicates) != 0 {
+		sample := duplicates
+		if len(sample) > 50 {
+			sample = sample[:50]
+		}
+		errs = append(errs, &DuplicateSerialError{Issuer: prefix, Count: len(duplicates), Sample: sample})
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package checker
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/checker/crltest"
+	"github.com/letsencrypt/crl-monitor/checker/testdata"
+	"github.com/letsencrypt/crl-monitor/storage"
+	storagemock "github.com/letsencrypt/crl-monitor/storage/mock"
+)
+
+func TestCheckShards(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	prefix := nameID(issuer)
+
+	makeCRL := func(shard string, serials ...int64) []byte {
+		var entries []x509.RevocationListEntry
+		for _, serial := range serials {
+			entries = append(entries, x509.RevocationListEntry{
+				SerialNumber:   big.NewInt(serial),
+				RevocationTime: testdata.Now,
+				ReasonCode:     testdata.CessationOfOperation,
+			})
+		}
+		return crltest.MakeCRL(t, &x509.RevocationList{
+			ThisUpdate:                testdata.Now,
+			NextUpdate:                testdata.Now.Add(24 * time.Hour),
+			Number:                    big.NewInt(1),
+			RevokedCertificateEntries: entries,
+		}, fmt.Sprintf("http://idp/%s", shard), issuer, key)
+	}
+
+	bucket := "crl-test"
+	ctx := context.Background()
+
+	good := New(Config{
+		Storage: storagemock.New(t, bucket, map[string][]storagemock.MockObject{
+			prefix + "/0.crl": {{VersionID: "v1", Data: makeCRL("0.crl", 1, 2)}},
+			prefix + "/1.crl": {{VersionID: "v1", Data: makeCRL("1.crl", 3)}},
+			// Previous versions and other issuers aren't considered
+			prefix + "/2.crl": {{VersionID: "v2", Data: makeCRL("2.crl", 4)}, {VersionID: "v1", Data: makeCRL("2.crl", 1)}},
+			"456/0.crl":       {{VersionID: "v1", Data: makeCRL("0.crl", 1)}},
+		}),
+		AgeLimit: 24 * time.Hour,
+		Issuers:  []*x509.Certificate{issuer},
+	})
+	require.NoError(t, good.CheckShards(ctx, bucket, prefix))
+	require.NoError(t, good.CheckAllShards(ctx, bucket))
+
+	bad := New(Config{
+		Storage: storagemock.New(t, bucket, map[string][]storagemock.MockObject{
+			prefix + "/0.crl": {{VersionID: "v1", Data: makeCRL("0.crl", 1, 2)}},
+			prefix + "/1.crl": {{VersionID: "v1", Data: makeCRL("1.crl", 2, 3)}},
+			prefix + "/2.crl": {{VersionID: "v1", Data: makeCRL("1.crl", 4)}},
+		}),
+		AgeLimit: 24 * time.Hour,
+		Issuers:  []*x509.Certificate{issuer},
+	})
+	err := bad.CheckShards(ctx, bucket, prefix)
+	require.ErrorContains(t, err, fmt.Sprintf("1 serials on more than one shard! First 1: [%036x:[%s/0.crl version v1 %s/1.crl version v1]]", 2, prefix, prefix))
+	require.ErrorContains(t, err, fmt.Sprintf(`crl %s/2.crl version v1: has IssuingDistributionPoint "http://idp/1.crl", which is for a different shard`, prefix))
+	require.ErrorContains(t, err, `2 shards have IssuingDistributionPoint "http://idp/1.crl"`)
+	require.ErrorContains(t, bad.CheckAllShards(ctx, bucket), "serials on more than one shard")
+	require.True(t, IsViolation(err))
+
+	var duplicates *DuplicateSerialError
+	require.ErrorAs(t, err, &duplicates)
+	require.Equal(t, prefix, duplicates.Issuer)
+	require.Equal(t, []DuplicateSerial{{Serial: big.NewInt(2), Shards: []string{prefix + "/0.crl version v1", prefix + "/1.crl version v1"}}}, duplicates.Sample)
+	var duplicateIDP *DuplicateIDPError
+	require.ErrorAs(t, err, &duplicateIDP)
+	require.Equal(t, "http://idp/1.crl", duplicateIDP.IDP)
+
+	alert := alertFor(bucket, prefix, nil, err)
+	require.Equal(t, []string{fmt.Sprintf("%036x", 2)}, alert.Serials)
+
+	// A shard that can't be fetched doesn't stop the others being checked
+	unfetchable := New(Config{
+		Storage: failingStorage{
+			Storage: storagemock.New(t, bucket, map[string][]storagemock.MockObject{
+				prefix + "/0.crl": {{VersionID: "v1", Data: makeCRL("0.crl", 1, 2)}},
+				prefix + "/1.crl": {{VersionID: "v1", Data: makeCRL("1.crl", 3)}},
+				prefix + "/2.crl": {{VersionID: "v1", Data: makeCRL("2.crl", 2)}},
+			}),
+			object: prefix + "/1.crl",
+		},
+		AgeLimit: 24 * time.Hour,
+		Issuers:  []*x509.Certificate{issuer},
+	})
+	err = unfetchable.CheckShards(ctx, bucket, prefix)
+	var storageErr *StorageError
+	require.ErrorAs(t, err, &storageErr)
+	require.Equal(t, prefix+"/1.crl", storageErr.Key.Object)
+	require.ErrorAs(t, err, &duplicates)
+	require.Equal(t, []DuplicateSerial{{Serial: big.NewInt(2), Shards: []string{prefix + "/0.crl version v1", prefix + "/2.crl version v1"}}}, duplicates.Sample)
+	require.True(t, IsViolation(err))
+}
+
+// failingStorage fails to fetch one object, and reads the rest from Storage.
+type failingStorage struct {
+	Storage
+	object string
+}
+
+func (fs failingStorage) Fetch(ctx context.Context, key storage.Key) ([]byte, string, error) {
+	if key.Object == fs.object {
+		return nil, "", errors.New("access denied")
+	}
+	return fs.Storage.Fetch(ctx, key)
+}
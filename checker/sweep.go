@@ -0,0 +1,237 @@
+package checker
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/crl-monitor/cmd"
+	"github.com/letsencrypt/crl-monitor/db"
+	"github.com/letsencrypt/crl-monitor/logging"
+	"github.com/letsencrypt/crl-monitor/metrics"
+	"github.com/letsencrypt/crl-monitor/notify"
+	"github.com/letsencrypt/crl-monitor/storage"
+)
+
+const (
+	StaleThreshold cmd.EnvVar = "STALE_THRESHOLD"
+	ExpectedShards cmd.EnvVar = "EXPECTED_SHARDS"
+)
+
+// defaultStaleThreshold is how long ago the latest version of a shard may have
+// been uploaded when STALE_THRESHOLD is unset.
+const defaultStaleThreshold = 24 * time.Hour
+
+// The Sweeper looks at every shard of each issuer, to catch shards that have
+// stopped being published, which the Checker can't as it only runs on upload.
+// Use NewSweeper to obtain one.
+type Sweeper struct {
+	storage Storage
+	// db holds the publication latency summaries to emit percentiles of. If
+	// nil, they aren't emitted.
+	db      *db.Database
+	issuers map[string]*x509.Certificate
+
+	// staleThreshold is how long ago the latest version of a shard may have
+	// been uploaded.
+	staleThreshold time.Duration
+	// expectedShards is how many shards each issuer should have. If zero,
+	// the number of shards isn't checked.
+	expectedShards int
+
+	metrics  metrics.Sink
+	notifier notify.Notifier
+}
+
+func NewSweeper(storage Storage, database *db.Database, issuers []*x509.Certificate, staleThreshold time.Duration, expectedShards int, metrics metrics.Sink, notifier notify.Notifier) *Sweeper {
+	issuerMap := make(map[string]*x509.Certificate, len(issuers))
+	for _, issuer := range issuers {
+		issuerMap[nameID(issuer)] = issuer
+	}
+
+	return &Sweeper{
+		storage:        storage,
+		db:             database,
+		issuers:        issuerMap,
+		staleThreshold: staleThreshold,
+		expectedShards: expectedShards,
+		metrics:        metrics,
+		notifier:       notifier,
+	}
+}
+
+func NewSweeperFromEnv(ctx context.Context) (*Sweeper, error) {
+	issuerPaths := IssuerPaths.MustRead("Colon (:) separated list of paths to PEM-formatted CRL issuer certificates")
+
+	staleThreshold := defaultStaleThreshold
+	staleThresholdString, hasStaleThreshold := StaleThreshold.LookupEnv()
+	if hasStaleThreshold {
+		var err error
+		staleThreshold, err = time.ParseDuration(staleThresholdString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", StaleThreshold, err)
+		}
+	}
+
+	expectedShards := 0
+	expectedShardsString, hasExpectedShards := ExpectedShards.LookupEnv()
+	if hasExpectedShards {
+		var err error
+		expectedShards, err = strconv.Atoi(expectedShardsString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s as int (%s): %v", ExpectedShards, expectedShardsString, err)
+		}
+	}
+
+	// The latency table is optional, and percentiles aren't emitted without it
+	var database *db.Database
+	if latencyTable, ok := DynamoLatencyTableEnv.LookupEnv(); ok {
+		dynamoEndpoint, _ := DynamoEndpointEnv.LookupEnv()
+		var err error
+		database, err = db.New(ctx, "", dynamoEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("database setup: %w", err)
+		}
+		database.LatencyTable = latencyTable
+	}
+
+	notifier, err := notify.FromEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSweeper(storage.New(ctx), database, loadIssuers(issuerPaths), staleThreshold, expectedShards, metrics.NewEMF(os.Stdout, metrics.Namespace), notifier), nil
+}
+
+// Sweep lists the shards under each issuer's prefix, and errors if an issuer
+// doesn't have the expected number of shards, if the latest version of a
+// shard was uploaded more than staleThreshold before now, or if its
+// NextUpdate has passed. Like Check, each issuer's errors are logged and
+// counted, and violations are sent to the notifier. If there's a latency
+// table, it also emits percentiles of the previous day's publication latency.
+func (s *Sweeper) Sweep(ctx context.Context, bucket string, now time.Time) error {
+	var errs []error
+	for _, prefix := range slices.Sorted(maps.Keys(s.issuers)) {
+		issuerCtx, logger := logging.With(ctx, logging.Bucket, bucket, logging.Issuer, prefix)
+
+		err := s.sweepIssuer(issuerCtx, bucket, prefix, now)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("issuer %s (CN=%s): %w", prefix, s.issuers[prefix].Subject.CommonName, err)
+		errs = append(errs, err)
+
+		severity, metric := "transient", "TransientErrors"
+		if IsViolation(err) {
+			severity, metric = "violation", "Violations"
+		}
+		logger.Error("error sweeping shards", "severity", severity, "errorType", fmt.Sprintf("%T", err), "error", err)
+		s.metrics.Emit(map[string]string{"Issuer": prefix}, metrics.Metric{Name: metric, Value: 1, Unit: metrics.Count})
+
+		if IsViolation(err) {
+			alert := alertFor(bucket, prefix+"/", nil, err)
+			alert.Source = "sweeper"
+			notifyErr := s.notifier.Notify(issuerCtx, alert)
+			if notifyErr != nil {
+				// The violation is still returned, so it's not lost
+				logger.Error("error sending alert", "error", notifyErr)
+			}
+		}
+	}
+
+	if s.db != nil {
+		err := s.emitLatency(ctx, now.Add(-24*time.Hour))
+		if err != nil {
+			logging.FromContext(ctx).Error("error summarizing publication latency", "severity", "transient", "errorType", fmt.Sprintf("%T", err), "error", err)
+			s.metrics.Emit(nil, metrics.Metric{Name: "TransientErrors", Value: 1, Unit: metrics.Count})
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// latencyPercentiles are the percentiles of publication latency emitted.
+var latencyPercentiles = []float64{50, 90, 99}
+
+// emitLatency emits percentiles of the publication latencies recorded by the
+// Checker on day, such as RevocationToCRLP99, for alarms on how long churned
+// certificates take to appear on a CRL. Each is the upper bound of its
+// histogram bucket, and one in the overflow bucket is emitted as the largest
+// bound, as it's at least that. Nothing is emitted for a day without any
+// latencies, so a missing churner can alarm on missing data.
+func (s *Sweeper) emitLatency(ctx context.Context, day time.Time) error {
+	summary, err := s.db.GetLatencySummary(ctx, day)
+	if err != nil {
+		return &LookupError{Err: err}
+	}
+
+	var emitted []metrics.Metric
+	for _, p := range latencyPercentiles {
+		for name, counts := range map[string][]int64{"RevocationToCRL": summary.ThisUpdate, "RevocationToUpload": summary.Uploaded} {
+			latency := db.Percentile(counts, p)
+			if latency == 0 {
+				continue
+			}
+			if latency < 0 {
+				latency = db.LatencyBuckets[len(db.LatencyBuckets)-1]
+			}
+			emitted = append(emitted, metrics.Metric{Name: fmt.Sprintf("%sP%g", name, p), Value: latency.Seconds(), Unit: metrics.Seconds})
+		}
+	}
+	logging.FromContext(ctx).Info("summarized publication latency", "day", summary.Day, "percentiles", len(emitted))
+	s.metrics.Emit(nil, emitted...)
+	return nil
+}
+
+func (s *Sweeper) sweepIssuer(ctx context.Context, bucket, prefix string, now time.Time) error {
+	objects, err := s.storage.List(ctx, bucket, prefix+"/")
+	if err != nil {
+		return &StorageError{Key: storage.Key{Bucket: bucket, Object: prefix + "/"}, Err: err}
+	}
+
+	var errs []error
+	var numShards int
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, ".crl") {
+			continue
+		}
+		numShards++
+
+		if now.Sub(object.LastModified) > s.staleThreshold {
+			errs = append(errs, &StaleShardError{Object: object.Key, LastModified: object.LastModified, Threshold: s.staleThreshold})
+		}
+
+		key := storage.Key{Bucket: bucket, Object: object.Key}
+		crlDER, version, err := s.storage.Fetch(ctx, key)
+		if err != nil {
+			// The other shards can still be checked
+			errs = append(errs, &StorageError{Key: key, Err: err})
+			continue
+		}
+
+		crl, err := x509.ParseRevocationList(crlDER)
+		if err != nil {
+			errs = append(errs, &LintError{Object: object.Key, Err: fmt.Errorf("parsing version %s: %v", version, err)})
+			continue
+		}
+
+		if !now.Before(crl.NextUpdate) {
+			errs = append(errs, &ExpiredShardError{Object: object.Key, Version: version, NextUpdate: crl.NextUpdate})
+		}
+	}
+	logging.FromContext(ctx).Info("swept shards", "shards", numShards)
+	s.metrics.Emit(map[string]string{"Issuer": prefix}, metrics.Metric{Name: "Shards", Value: float64(numShards), Unit: metrics.Count})
+
+	if s.expectedShards != 0 && numShards != s.expectedShards {
+		errs = append(errs, &ShardCountError{Issuer: prefix, Found: numShards, Expected: s.expectedShards})
+	}
+
+	return errors.Join(errs...)
+}
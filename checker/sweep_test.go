@@ -0,0 +1,111 @@
+package checker
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/checker/crltest"
+	"github.com/letsencrypt/crl-monitor/checker/testdata"
+	dbmock "github.com/letsencrypt/crl-monitor/db/mock"
+	"github.com/letsencrypt/crl-monitor/metrics"
+	metricsmock "github.com/letsencrypt/crl-monitor/metrics/mock"
+	"github.com/letsencrypt/crl-monitor/notify"
+	notifymock "github.com/letsencrypt/crl-monitor/notify/mock"
+	storagemock "github.com/letsencrypt/crl-monitor/storage/mock"
+)
+
+func TestSweep(t *testing.T) {
+	issuer, key := crltest.MakeIssuer(t)
+	prefix := nameID(issuer)
+
+	makeCRL := func(shard string, nextUpdate time.Time) []byte {
+		return crltest.MakeCRL(t, &x509.RevocationList{
+			ThisUpdate: testdata.Now,
+			NextUpdate: nextUpdate,
+			Number:     big.NewInt(1),
+		}, fmt.Sprintf("http://idp/%s", shard), issuer, key)
+	}
+
+	bucket := "crl-test"
+	ctx := context.Background()
+	now := testdata.Now.Add(time.Hour)
+	fresh := testdata.Now
+	stale := testdata.Now.Add(-48 * time.Hour)
+	nextUpdate := testdata.Now.Add(24 * time.Hour)
+
+	data := map[string][]storagemock.MockObject{
+		prefix + "/0.crl": {{VersionID: "v1", Data: makeCRL("0.crl", nextUpdate), LastModified: fresh}},
+		prefix + "/1.crl": {{VersionID: "v1", Data: makeCRL("1.crl", nextUpdate), LastModified: fresh}},
+		// Other issuers aren't considered
+		"456/0.crl": {{VersionID: "v1", Data: makeCRL("0.crl", nextUpdate), LastModified: stale}},
+	}
+	sweeper := NewSweeper(storagemock.New(t, bucket, data), nil, []*x509.Certificate{issuer}, 24*time.Hour, 2, metrics.Discard, notify.Discard)
+	require.NoError(t, sweeper.Sweep(ctx, bucket, now))
+
+	// Without an expected number of shards, the count isn't checked
+	sweeper = NewSweeper(storagemock.New(t, bucket, data), nil, []*x509.Certificate{issuer}, 24*time.Hour, 0, metrics.Discard, notify.Discard)
+	require.NoError(t, sweeper.Sweep(ctx, bucket, now))
+
+	recorder := &metricsmock.Recorder{}
+	notifier := &notifymock.Recorder{}
+	sweeper = NewSweeper(storagemock.New(t, bucket, map[string][]storagemock.MockObject{
+		prefix + "/0.crl": {{VersionID: "v1", Data: makeCRL("0.crl", nextUpdate), LastModified: stale}},
+		prefix + "/1.crl": {{VersionID: "v1", Data: makeCRL("1.crl", testdata.Now.Add(time.Minute)), LastModified: fresh}},
+	}), nil, []*x509.Certificate{issuer}, 24*time.Hour, 3, recorder, notifier)
+	err := sweeper.Sweep(ctx, bucket, now)
+	require.ErrorContains(t, err, fmt.Sprintf("shard %s/0.crl was last uploaded at %s, more than 24h0m0s ago", prefix, stale))
+	require.ErrorContains(t, err, fmt.Sprintf("shard %s/1.crl version v1 has nextUpdate", prefix))
+	require.ErrorContains(t, err, "found 2 shards, expected 3")
+	require.ErrorContains(t, err, fmt.Sprintf("issuer %s (CN=%s)", prefix, issuer.Subject.CommonName))
+	require.True(t, IsViolation(err))
+	var staleErr *StaleShardError
+	require.ErrorAs(t, err, &staleErr)
+	require.Equal(t, prefix+"/0.crl", staleErr.Object)
+	var expiredErr *ExpiredShardError
+	require.ErrorAs(t, err, &expiredErr)
+	require.Equal(t, prefix+"/1.crl", expiredErr.Object)
+	var countErr *ShardCountError
+	require.ErrorAs(t, err, &countErr)
+	require.Equal(t, 2, countErr.Found)
+
+	require.Equal(t, []float64{2}, recorder.Values("Shards"))
+	require.Equal(t, []float64{1}, recorder.Values("Violations"))
+	alerts := notifier.Alerts()
+	require.Len(t, alerts, 1)
+	require.Equal(t, "sweeper", alerts[0].Source)
+	require.Equal(t, "StaleShardError", alerts[0].Type)
+	require.Equal(t, prefix+"/", alerts[0].Shard)
+	require.Equal(t, []string{"v1"}, alerts[0].Versions)
+}
+
+func TestSweepLatency(t *testing.T) {
+	ctx := context.Background()
+	now := testdata.Now
+	yesterday := now.Add(-24 * time.Hour)
+
+	database := dbmock.NewMockedDB(t)
+	recorder := &metricsmock.Recorder{}
+	sweeper := NewSweeper(storagemock.New(t, "crl-test", nil), database, nil, 24*time.Hour, 0, recorder, notify.Discard)
+
+	// Without any latencies recorded, nothing is emitted
+	require.NoError(t, sweeper.Sweep(ctx, "crl-test", now))
+	require.Empty(t, recorder.Values("RevocationToCRLP50"))
+
+	for _, latency := range []time.Duration{time.Minute, 2 * time.Minute, 20 * time.Minute, 72 * time.Hour} {
+		require.NoError(t, database.RecordPublicationLatency(ctx, yesterday, latency, time.Hour))
+	}
+	// Today's latencies aren't included
+	require.NoError(t, database.RecordPublicationLatency(ctx, now, 72*time.Hour, 72*time.Hour))
+
+	require.NoError(t, sweeper.Sweep(ctx, "crl-test", now))
+	require.Equal(t, []float64{300}, recorder.Values("RevocationToCRLP50"))
+	// The overflow bucket is emitted as the largest bound
+	require.Equal(t, []float64{48 * 3600}, recorder.Values("RevocationToCRLP99"))
+	require.Equal(t, []float64{3600}, recorder.Values("RevocationToUploadP99"))
+}
@@ -0,0 +1,101 @@
+package churner
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/letsencrypt/crl-monitor/db"
+)
+
+// AccountStore persists the churner's ACME account between runs, so it isn't
+// registering a new one each time. It is fulfilled by *db.Database, and by
+// FileAccountStore for local use.
+type AccountStore interface {
+	// GetAccount returns db.ErrNoAccount if no account is stored for the
+	// directory.
+	GetAccount(ctx context.Context, directory string) (*db.Account, error)
+	PutAccount(ctx context.Context, account *db.Account) error
+}
+
+// FileAccountStore is an AccountStore which keeps each account as a JSON file
+// in Dir. The files contain the account's private key, so are only readable
+// by their owner.
+type FileAccountStore struct {
+	Dir string
+}
+
+// path is the file an ACME directory's account is kept in.
+func (f FileAccountStore) path(directory string) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("account-%x.json", sha256.Sum256([]byte(directory))))
+}
+
+func (f FileAccountStore) GetAccount(_ context.Context, directory string) (*db.Account, error) {
+	data, err := os.ReadFile(f.path(directory))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, db.ErrNoAccount
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading account for %s: %w", directory, err)
+	}
+
+	var account db.Account
+	err = json.Unmarshal(data, &account)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling account for %s: %w", directory, err)
+	}
+	return &account, nil
+}
+
+// PutAccount creates Dir if it doesn't exist, and replaces the account's file
+// by renaming a temporary file over it, so an interrupted write can't leave a
+// truncated account behind.
+func (f FileAccountStore) PutAccount(_ context.Context, account *db.Account) error {
+	data, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(f.Dir, 0o700)
+	if err != nil {
+		return fmt.Errorf("creating account directory: %w", err)
+	}
+
+	// CreateTemp makes the file readable only by its owner
+	tmp, err := os.CreateTemp(f.Dir, "account-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating account file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err != nil || closeErr != nil {
+		return fmt.Errorf("writing account for %s: %w", account.Directory, errors.Join(err, closeErr))
+	}
+
+	err = os.Rename(tmp.Name(), f.path(account.Directory))
+	if err != nil {
+		return fmt.Errorf("replacing account for %s: %w", account.Directory, err)
+	}
+	return nil
+}
+
+// signer asserts that a parsed private key can sign, as all the key types
+// x509.ParsePKCS8PrivateKey returns can.
+func signer(key any) (crypto.Signer, error) {
+	s, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("account key of type %T can't sign", key)
+	}
+	return s, nil
+}
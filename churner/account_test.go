@@ -0,0 +1,182 @@
+package churner
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mholt/acmez/v3/acme"
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/db"
+	"github.com/letsencrypt/crl-monitor/metrics"
+	"github.com/letsencrypt/crl-monitor/notify"
+)
+
+// fakeAccountServer implements just enough of ACME to register and look up
+// accounts. Accounts are identified by the JWK they were registered with, and
+// signatures aren't checked.
+type fakeAccountServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// statuses maps the JWK of each account to its status
+	statuses  map[string]string
+	locations map[string]string
+	// registrations counts calls to newAccount which created an account
+	registrations int
+}
+
+func newFakeAccountServer(t *testing.T) *fakeAccountServer {
+	f := &fakeAccountServer{statuses: make(map[string]string), locations: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /directory", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]string{
+			"newNonce":   f.URL + "/nonce",
+			"newAccount": f.URL + "/new-account",
+			"newOrder":   f.URL + "/new-order",
+		}))
+	})
+	mux.HandleFunc("HEAD /nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+	})
+	mux.HandleFunc("POST /new-account", func(w http.ResponseWriter, r *http.Request) {
+		var jws struct{ Protected, Payload string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&jws))
+		var protected struct{ JWK json.RawMessage }
+		decodeSegment(t, jws.Protected, &protected)
+		var payload struct{ OnlyReturnExisting bool }
+		decodeSegment(t, jws.Payload, &payload)
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		jwk := string(protected.JWK)
+		w.Header().Set("Replay-Nonce", "nonce")
+		w.Header().Set("Content-Type", "application/json")
+		status, exists := f.statuses[jwk]
+		switch {
+		case exists && status != acme.StatusValid:
+			problem(w, http.StatusForbidden, acme.ProblemTypeUnauthorized, "account is not valid, has status "+status)
+		case exists:
+			w.Header().Set("Location", f.locations[jwk])
+			require.NoError(t, json.NewEncoder(w).Encode(acme.Account{Status: status}))
+		case payload.OnlyReturnExisting:
+			problem(w, http.StatusBadRequest, acme.ProblemTypeAccountDoesNotExist, "no account exists")
+		default:
+			f.registrations++
+			f.statuses[jwk] = acme.StatusValid
+			f.locations[jwk] = fmt.Sprintf("%s/account/%d", f.URL, f.registrations)
+			w.Header().Set("Location", f.locations[jwk])
+			w.WriteHeader(http.StatusCreated)
+			require.NoError(t, json.NewEncoder(w).Encode(acme.Account{Status: acme.StatusValid}))
+		}
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func decodeSegment(t *testing.T, segment string, v any) {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, v))
+}
+
+func problem(w http.ResponseWriter, status int, problemType, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(acme.Problem{Type: problemType, Detail: detail, Status: status})
+}
+
+// deactivate marks every account as deactivated
+func (f *fakeAccountServer) deactivate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for jwk := range f.statuses {
+		f.statuses[jwk] = acme.StatusDeactivated
+	}
+}
+
+func TestRegisterAccount(t *testing.T) {
+	accountCreationDelay = 0
+	defer func() { accountCreationDelay = 2 * time.Second }()
+
+	ctx := context.Background()
+	server := newFakeAccountServer(t)
+	directory := server.URL + "/directory"
+	store := FileAccountStore{Dir: t.TempDir()}
+
+	newChurner := func() *Churner {
+		c, err := New("revoked.invalid", directory, nil, nil, store, nil, time.Now(), DefaultInterval, metrics.Discard, notify.Discard)
+		require.NoError(t, err)
+		return c
+	}
+
+	// With nothing stored, an account is registered and stored
+	first := newChurner()
+	require.NoError(t, first.RegisterAccount(ctx))
+	require.Equal(t, 1, server.registrations)
+	stored, err := store.GetAccount(ctx, directory)
+	require.NoError(t, err)
+	require.Equal(t, server.URL+"/account/1", stored.Location)
+	require.Equal(t, stored.Location, first.acmeAccount.Location)
+
+	// The next run reuses it
+	second := newChurner()
+	require.NoError(t, second.RegisterAccount(ctx))
+	require.Equal(t, 1, server.registrations)
+	require.Equal(t, stored.Location, second.acmeAccount.Location)
+	require.Equal(t, first.acmeAccount.PrivateKey, second.acmeAccount.PrivateKey)
+
+	// Once deactivated, a new account is registered and replaces it
+	server.deactivate()
+	third := newChurner()
+	require.NoError(t, third.RegisterAccount(ctx))
+	require.Equal(t, 2, server.registrations)
+	stored, err = store.GetAccount(ctx, directory)
+	require.NoError(t, err)
+	require.Equal(t, server.URL+"/account/2", stored.Location)
+	require.Equal(t, stored.Location, third.acmeAccount.Location)
+}
+
+func TestFileAccountStore(t *testing.T) {
+	ctx := context.Background()
+	// The directory is created on the first write
+	store := FileAccountStore{Dir: filepath.Join(t.TempDir(), "accounts")}
+
+	_, err := store.GetAccount(ctx, "https://acme.example/directory")
+	require.ErrorIs(t, err, db.ErrNoAccount)
+
+	account := &db.Account{Directory: "https://acme.example/directory", Location: "https://acme.example/acct/1", Key: []byte{1, 2, 3}}
+	require.NoError(t, store.PutAccount(ctx, account))
+	loaded, err := store.GetAccount(ctx, account.Directory)
+	require.NoError(t, err)
+	require.Equal(t, account, loaded)
+
+	info, err := os.Stat(store.path(account.Directory))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Replacing the account leaves no temporary files behind
+	account.Location = "https://acme.example/acct/2"
+	require.NoError(t, store.PutAccount(ctx, account))
+	loaded, err = store.GetAccount(ctx, account.Directory)
+	require.NoError(t, err)
+	require.Equal(t, account, loaded)
+	entries, err := os.ReadDir(store.Dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// Each directory has its own account
+	_, err = store.GetAccount(ctx, "https://other.example/directory")
+	require.ErrorIs(t, err, db.ErrNoAccount)
+}
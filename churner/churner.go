@@ -6,24 +6,27 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
+	"maps"
 	mathrand "math/rand/v2"
 	"os"
+	"slices"
 	"time"
 
-	"github.com/caddyserver/certmagic"
-	"github.com/libdns/route53"
 	"github.com/mholt/acmez/v3"
 	"github.com/mholt/acmez/v3/acme"
 
 	"github.com/letsencrypt/boulder/crl/checker"
 	"github.com/letsencrypt/crl-monitor/cmd"
 	"github.com/letsencrypt/crl-monitor/db"
+	"github.com/letsencrypt/crl-monitor/logging"
+	"github.com/letsencrypt/crl-monitor/metrics"
+	"github.com/letsencrypt/crl-monitor/notify"
 	"github.com/letsencrypt/crl-monitor/retryhttp"
 )
 
@@ -33,46 +36,70 @@ const (
 	DynamoTableEnv    cmd.EnvVar = "DYNAMO_TABLE"
 	DynamoEndpointEnv cmd.EnvVar = "DYNAMO_ENDPOINT"
 	RevokeDeadline    cmd.EnvVar = "REVOKE_DEADLINE"
+	AccountTableEnv   cmd.EnvVar = "DYNAMO_ACCOUNT_TABLE"
+	AccountDirEnv     cmd.EnvVar = "ACCOUNT_DIR"
+	VariantsEnv       cmd.EnvVar = "CHURN_VARIANTS"
+	IntervalEnv       cmd.EnvVar = "CHURN_INTERVAL"
 )
 
+// DefaultInterval is how often the churner is expected to run, if
+// CHURN_INTERVAL isn't set.
+const DefaultInterval = time.Hour
+
 // The Churner creats and immediately revokes certificates. Certificates are
 // issued using the configured ACME client using DNS01 challenges under the
-// configured baseDomain. Serials and revocation time are stored in the db.
+// configured baseDomain, one for each variant. Serials and revocation time
+// are stored in the db.
 type Churner struct {
 	baseDomain  string
+	variants    []Variant
 	acmeClient  acmez.Client
 	acmeAccount acme.Account
-	db          *db.Database
-	cutoff      time.Time
+	// accounts stores the ACME account for reuse. If nil, a new account is
+	// registered on every run.
+	accounts AccountStore
+	db       *db.Database
+	cutoff   time.Time
+	// interval is how often the churner runs, which sets how often it moves
+	// on to the next way of revoking.
+	interval time.Duration
+	metrics  metrics.Sink
+	notifier notify.Notifier
 }
 
 // New returns a Churner with an ACME client configured.
-// `baseDomain` should be a domain name that the `dnsProvider` can create/delete
-// records for. The certs will be issued from the CA at `acmeDirectory`.
-// The resulting serials are stored into `db`
-func New(baseDomain string, acmeDirectory string, dnsProvider certmagic.DNSProvider, db *db.Database, cutoff time.Time) (*Churner, error) {
-	slogger := slog.New(slog.NewTextHandler(os.Stderr, nil))
-
+// `baseDomain` should be a domain name that the `solver` can solve DNS-01
+// challenges for. A certificate for each of the `variants`
+// will be issued from the CA at `acmeDirectory` on each run.
+// The ACME account is kept in `accounts`, if not nil.
+// The churner is run every `interval`, and each run revokes differently.
+// The resulting serials are stored into `db`, timings are sent to `metrics`,
+// and certs missing from CRLs are sent to `notifier`.
+func New(baseDomain string, acmeDirectory string, solver acmez.Solver, variants []Variant, accounts AccountStore, db *db.Database, cutoff time.Time, interval time.Duration, metrics metrics.Sink, notifier notify.Notifier) (*Churner, error) {
 	acmeClient := acmez.Client{
 		Client: &acme.Client{
 			Directory: acmeDirectory,
-			Logger:    slogger,
+			Logger:    slog.Default(),
 		},
 		ChallengeSolvers: map[string]acmez.Solver{
-			acme.ChallengeTypeDNS01: &certmagic.DNS01Solver{
-				DNSManager: certmagic.DNSManager{
-					DNSProvider:      dnsProvider,
-					PropagationDelay: 60 * time.Second, // Route53 docs say 60 seconds in normal conditions,
-				},
-			},
+			acme.ChallengeTypeDNS01: solver,
 		},
 	}
 
+	if interval < time.Second {
+		return nil, fmt.Errorf("interval %s is less than a second", interval)
+	}
+
 	return &Churner{
 		baseDomain: baseDomain,
+		variants:   variants,
 		acmeClient: acmeClient,
+		accounts:   accounts,
 		db:         db,
 		cutoff:     cutoff,
+		interval:   interval,
+		metrics:    metrics,
+		notifier:   notifier,
 	}, nil
 }
 
@@ -94,14 +121,70 @@ func NewFromEnv(ctx context.Context) (*Churner, error) {
 		log.Fatalf("Error in database setup: %v", err)
 	}
 
-	dnsProvider := route53.Provider{}
+	// Accounts are kept in DynamoDB if there's a table for them, or else in a
+	// local directory. Without either, a new account is registered each run.
+	var accounts AccountStore
+	accountTable, hasAccountTable := AccountTableEnv.LookupEnv()
+	accountDir, hasAccountDir := AccountDirEnv.LookupEnv()
+	if hasAccountTable {
+		database.AccountTable = accountTable
+		accounts = database
+	} else if hasAccountDir {
+		accounts = FileAccountStore{Dir: accountDir}
+	}
+
+	variantsString, hasVariants := VariantsEnv.LookupEnv()
+	if !hasVariants {
+		variantsString = DefaultVariants
+	}
+	variants, err := ParseVariants(variantsString)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", VariantsEnv, err)
+	}
+
+	interval := DefaultInterval
+	intervalString, hasInterval := IntervalEnv.LookupEnv()
+	if hasInterval {
+		interval, err = time.ParseDuration(intervalString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", IntervalEnv, err)
+		}
+	}
 
-	return New(baseDomain, acmeDirectory, &dnsProvider, database, cutoff)
+	solver, err := dns01SolverFromEnv(baseDomain, acmeDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	notifier, err := notify.FromEnv(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("notifier setup: %w", err)
+	}
+
+	return New(baseDomain, acmeDirectory, solver, variants, accounts, database, cutoff, interval, metrics.NewEMF(os.Stdout, metrics.Namespace), notifier)
 }
 
-// RegisterAccount sets up a new account.
-// TODO: Store accounts to reuse.  For now we just make a new one each time.
+// accountCreationDelay is how long to wait after registering an account
+// before using it.
+var accountCreationDelay = 2 * time.Second
+
+// RegisterAccount sets up the ACME account. The stored account is reused if
+// it's still valid, and otherwise a new account is registered and stored.
 func (c *Churner) RegisterAccount(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+
+	if c.accounts != nil {
+		account, ok, err := c.loadAccount(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			logger.Info("reusing stored ACME account", "account", account.Location)
+			c.acmeAccount = account
+			return nil
+		}
+	}
+
 	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		return fmt.Errorf("generating account key: %w", err)
@@ -111,16 +194,71 @@ func (c *Churner) RegisterAccount(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating ACME account: %w", err)
 	}
+	logger.Info("registered ACME account", "account", account.Location)
 
 	c.acmeAccount = account
 
+	if c.accounts != nil {
+		der, err := x509.MarshalPKCS8PrivateKey(accountKey)
+		if err != nil {
+			return fmt.Errorf("marshalling account key: %w", err)
+		}
+		err = c.accounts.PutAccount(ctx, &db.Account{
+			Directory: c.acmeClient.Directory,
+			Location:  account.Location,
+			Key:       der,
+		})
+		if err != nil {
+			return fmt.Errorf("storing ACME account: %w", err)
+		}
+	}
+
 	// Account creation isn't immediately consistent across all DCs.
-	// Sleep 2 seconds to avoid any potential problems.
-	time.Sleep(2 * time.Second)
+	// Sleep to avoid any potential problems.
+	time.Sleep(accountCreationDelay)
 	return nil
 }
 
-func (c *Churner) retryObtain(ctx context.Context, certPrivateKey crypto.Signer, sans []string) ([]acme.Certificate, error) {
+// loadAccount returns the stored account for the ACME directory. It returns
+// false if there isn't one, or if the CA no longer considers it valid, such as
+// when it's been deactivated.
+func (c *Churner) loadAccount(ctx context.Context) (acme.Account, bool, error) {
+	stored, err := c.accounts.GetAccount(ctx, c.acmeClient.Directory)
+	if errors.Is(err, db.ErrNoAccount) {
+		return acme.Account{}, false, nil
+	}
+	if err != nil {
+		return acme.Account{}, false, fmt.Errorf("loading ACME account: %w", err)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(stored.Key)
+	if err != nil {
+		return acme.Account{}, false, fmt.Errorf("parsing stored account key: %w", err)
+	}
+	accountKey, err := signer(parsed)
+	if err != nil {
+		return acme.Account{}, false, err
+	}
+
+	// Look up the account's current status with the CA
+	account, err := c.acmeClient.GetAccount(ctx, acme.Account{PrivateKey: accountKey})
+	var problem acme.Problem
+	if errors.As(err, &problem) && (problem.Type == acme.ProblemTypeAccountDoesNotExist || problem.Type == acme.ProblemTypeUnauthorized) {
+		logging.FromContext(ctx).Warn("stored ACME account is no longer usable", "account", stored.Location, "error", err)
+		return acme.Account{}, false, nil
+	}
+	if err != nil {
+		return acme.Account{}, false, fmt.Errorf("looking up stored ACME account %s: %w", stored.Location, err)
+	}
+	if account.Status != acme.StatusValid {
+		logging.FromContext(ctx).Warn("stored ACME account is no longer valid", "account", stored.Location, "status", account.Status)
+		return acme.Account{}, false, nil
+	}
+	return account, true, nil
+}
+
+func (c *Churner) retryObtain(ctx context.Context, certPrivateKey crypto.Signer, sans []string, profile string) ([]acme.Certificate, error) {
+	logger := logging.FromContext(ctx)
 	csr, err := acmez.NewCSR(certPrivateKey, sans)
 	if err != nil {
 		return nil, err
@@ -129,11 +267,12 @@ func (c *Churner) retryObtain(ctx context.Context, certPrivateKey crypto.Signer,
 	if err != nil {
 		return nil, err
 	}
+	params.Profile = profile
 	var certificates []acme.Certificate
 	for retry := 0; retry < 5; retry++ {
 		certificates, err = c.acmeClient.ObtainCertificate(ctx, params)
 		if err != nil {
-			log.Printf("error obtaining certificate on retry %d: %v", retry, err)
+			logger.Warn("error obtaining certificate", "retry", retry, "error", err)
 			time.Sleep(time.Second)
 			continue
 		}
@@ -142,17 +281,50 @@ func (c *Churner) retryObtain(ctx context.Context, certPrivateKey crypto.Signer,
 	return nil, err
 }
 
-// Churn issues a certificate, revokes it, and stores the result in DynamoDB
+// Churn issues a certificate of each variant, revokes it, and stores the
+// result in DynamoDB. A failure with one variant doesn't stop the others.
 func (c *Churner) Churn(ctx context.Context) error {
-	certPrivateKey, err := randomKey()
+	run := time.Now().Unix() / int64(c.interval/time.Second)
+	var errs []error
+	for i, variant := range c.variants {
+		err := c.churnVariant(ctx, variant, revocationFor(run, i))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("variant %q: %w", variant.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// churnVariant issues a certificate of one variant, revokes it as given, and
+// stores the result in DynamoDB.
+func (c *Churner) churnVariant(ctx context.Context, variant Variant, revocation db.Revocation) error {
+	domains := variant.domains(c.baseDomain)
+	ctx, logger := logging.With(ctx, "variant", variant.Name, "domains", domains)
+	// Log the ACME client's requests for this run along with everything else
+	c.acmeClient.Logger = logger
+
+	if variant.Profile != "" {
+		directory, err := c.acmeClient.GetDirectory(ctx)
+		if err != nil {
+			return fmt.Errorf("getting ACME directory: %w", err)
+		}
+		if !offersProfile(directory, variant.Profile) {
+			logger.Warn("skipping variant, as the CA doesn't offer its profile", "profile", variant.Profile)
+			return nil
+		}
+	}
+
+	certPrivateKey, err := variant.key()
 	if err != nil {
 		return err
 	}
 
-	certificates, err := c.retryObtain(ctx, certPrivateKey, randDomains(c.baseDomain))
+	start := time.Now()
+	certificates, err := c.retryObtain(ctx, certPrivateKey, domains, variant.Profile)
 	if err != nil {
 		return err
 	}
+	c.metrics.Emit(nil, metrics.Metric{Name: "IssuanceLatency", Value: time.Since(start).Seconds(), Unit: metrics.Seconds})
 
 	// certificates contains all the possible cert chains.  We don't
 	// care about alternate chains, but we do care about getting
@@ -168,6 +340,8 @@ func (c *Churner) Churn(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	logger = logger.With(logging.Serial, fmt.Sprintf("%036x", cert.SerialNumber), "issuerCommonName", issuer.Subject.CommonName)
+	logger.Info("obtained certificate", "crlDistributionPoints", cert.CRLDistributionPoints)
 
 	// If the certificate has any CRLDistributionPoints, check that they can be fetched,
 	// parsed, verified, and linted. We don't try to check for revocation at this stage
@@ -194,26 +368,57 @@ func (c *Churner) Churn(ctx context.Context) error {
 				return fmt.Errorf("certificate %x was found on CRL %s before it was revoked", cert.SerialNumber, url)
 			}
 		}
+		logger.Info("checked crl before revocation", logging.IDP, url, logging.CRLNumber, crl.Number.String())
+	}
+
+	signingKey := c.acmeAccount.PrivateKey
+	if revocation.Method == db.RevokedByCertKey {
+		signingKey = certPrivateKey
 	}
 
-	err = c.acmeClient.RevokeCertificate(ctx, c.acmeAccount, cert, c.acmeAccount.PrivateKey, acme.ReasonCessationOfOperation)
+	start = time.Now()
+	err = c.acmeClient.RevokeCertificate(ctx, c.acmeAccount, cert, signingKey, revocation.ReasonCode)
 	if err != nil {
-		return err
+		return fmt.Errorf("revoking by %s with reason %d: %w", revocation.Method, revocation.ReasonCode, err)
 	}
+	c.metrics.Emit(nil, metrics.Metric{Name: "RevocationLatency", Value: time.Since(start).Seconds(), Unit: metrics.Seconds})
+	logger.Info("revoked certificate", "revocationMethod", revocation.Method, "reasonCode", revocation.ReasonCode)
 
-	return c.db.AddCert(ctx, cert, time.Now())
+	revocation.Time = time.Now()
+	return c.db.AddCert(ctx, cert, variant.Name, revocation)
 }
 
-// randomKey generates either an ecdsa or rsa private key
-func randomKey() (crypto.Signer, error) {
-	if mathrand.IntN(2) == 0 {
-		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	} else {
-		return rsa.GenerateKey(rand.Reader, 2048)
+// offersProfile reports whether the CA's directory advertises profile. The
+// description of a profile may be empty, so only its presence counts.
+func offersProfile(directory acme.Directory, profile string) bool {
+	if directory.Meta == nil {
+		return false
 	}
+	_, ok := directory.Meta.Profiles[profile]
+	return ok
+}
+
+// revocations are the ways the churner revokes certificates, so that each
+// path through the CA is exercised. Subscribers can request unspecified,
+// keyCompromise, superseded or cessationOfOperation. Revoking with the
+// certificate's key always results in keyCompromise, whatever is requested.
+var revocations = []db.Revocation{
+	{Method: db.RevokedByAccount, ReasonCode: acme.ReasonUnspecified},
+	{Method: db.RevokedByAccount, ReasonCode: acme.ReasonKeyCompromise},
+	{Method: db.RevokedByAccount, ReasonCode: acme.ReasonSuperseded},
+	{Method: db.RevokedByAccount, ReasonCode: acme.ReasonCessationOfOperation},
+	{Method: db.RevokedByCertKey, ReasonCode: acme.ReasonKeyCompromise},
+}
+
+// revocationFor picks how to revoke the certificate of the i'th variant in a
+// run, numbered by how many intervals have passed since the epoch. Each run
+// starts from the way after the previous run's, so every way is used within
+// len(revocations) consecutive runs.
+func revocationFor(run int64, i int) db.Revocation {
+	return revocations[(run+int64(i))%int64(len(revocations))]
 }
 
-// randDomains picks the domains to include on the certificate.
+// randDomains picks a domain to include on a certificate.
 // We put a single domain which includes the current time and a random value.
 func randDomains(baseDomain string) []string {
 	domain := fmt.Sprintf("r%dz%x.%s", time.Now().Unix(), mathrand.Uint32(), baseDomain)
@@ -221,19 +426,35 @@ func randDomains(baseDomain string) []string {
 }
 
 // CheckMissing looks if previously stored serials are still in the database, meaning they
-// haven't been seen in a CRL.  CheckMissing returns all certs revoked before a cutoff time.
+// haven't been seen in a CRL.  CheckMissing returns all certs revoked before a cutoff time,
+// and sends an alert for each CRL they're missing from.
 func (c *Churner) CheckMissing(ctx context.Context) ([]db.CertMetadata, error) {
-	// TODO:  This calls GetAllCerts and filters client-side instead of using an efficient query.
-	unseenCerts, err := c.db.GetAllCerts(ctx)
+	// If the cert was revoked before the cutoff, we should have seen it
+	unseenCerts, err := c.db.GetCertsRevokedBefore(ctx, c.cutoff)
 	if err != nil {
 		return nil, fmt.Errorf("retrieving unseen certificates: %w", err)
 	}
 
 	var missed []db.CertMetadata
+	byIDP := make(map[string][]string)
 	for _, cert := range unseenCerts {
-		// If the cert was revoked before the cutoff, we should have seen it
-		if cert.RevocationTime.Before(c.cutoff) {
-			missed = append(missed, cert)
+		missed = append(missed, cert)
+		byIDP[cert.CRLDistributionPoint] = append(byIDP[cert.CRLDistributionPoint], cert.SerialString())
+	}
+
+	for _, idp := range slices.Sorted(maps.Keys(byIDP)) {
+		serials := byIDP[idp]
+		slices.Sort(serials)
+		err = c.notifier.Notify(ctx, notify.Alert{
+			Source:  "churner",
+			Type:    "MissingFromCRL",
+			Message: fmt.Sprintf("%d certificates revoked before %s didn't appear in CRL in time", len(serials), c.cutoff.Format(time.RFC3339)),
+			Shard:   idp,
+			Serials: serials,
+		})
+		if err != nil {
+			// The missing certs are still returned, so they're not lost
+			logging.FromContext(ctx).Error("error sending alert", logging.IDP, idp, "error", err)
 		}
 	}
 	return missed, nil
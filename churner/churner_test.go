@@ -8,10 +8,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/mholt/acmez/v3/acme"
 	"github.com/stretchr/testify/require"
 
 	"github.com/letsencrypt/crl-monitor/db"
 	"github.com/letsencrypt/crl-monitor/db/mock"
+	notifymock "github.com/letsencrypt/crl-monitor/notify/mock"
 )
 
 func TestRandDomains(t *testing.T) {
@@ -24,26 +26,57 @@ func TestRandDomains(t *testing.T) {
 	require.NotEqual(t, domains, second, "Domains should be different each invocation")
 }
 
+func TestRevocationFor(t *testing.T) {
+	seen := make(map[db.Revocation]bool)
+	for run := range int64(len(revocations)) {
+		seen[revocationFor(run, 0)] = true
+		// Later variants in a run pick the ways later runs start from
+		require.Equal(t, revocationFor(run+1, 0), revocationFor(run, 1))
+	}
+	require.Len(t, seen, len(revocations), "Every revocation should be picked in consecutive runs")
+	require.Equal(t, revocationFor(0, 0), revocationFor(int64(len(revocations)), 0))
+}
+
+func TestOffersProfile(t *testing.T) {
+	directory := acme.Directory{Meta: &acme.DirectoryMeta{Profiles: map[string]string{
+		"classic":    "The same profile you're accustomed to",
+		"shortlived": "",
+	}}}
+	require.True(t, offersProfile(directory, "classic"))
+	require.True(t, offersProfile(directory, "shortlived"), "A profile without a description is still offered")
+	require.False(t, offersProfile(directory, "tlsserver"))
+	require.False(t, offersProfile(acme.Directory{}, "classic"))
+}
+
 func TestCheckMissing(t *testing.T) {
 	now := time.Now()
 	ctx := context.Background()
 
-	churner := Churner{db: mock.NewMockedDB(t), cutoff: now.Add(-24 * time.Hour)}
+	notifier := notifymock.Recorder{}
+	churner := Churner{db: mock.NewMockedDB(t), cutoff: now.Add(-24 * time.Hour), notifier: &notifier}
 
 	sn1 := big.NewInt(1111111)
 	sn2 := big.NewInt(2022)
 
 	yesterday := now.Add(-25 * time.Hour)
+	crlDP := "http://c.example.com/1.crl"
 
-	require.NoError(t, churner.db.AddCert(ctx, &x509.Certificate{SerialNumber: sn1}, yesterday))
-	require.NoError(t, churner.db.AddCert(ctx, &x509.Certificate{SerialNumber: sn2}, now))
+	require.NoError(t, churner.db.AddCert(ctx, &x509.Certificate{SerialNumber: sn1, CRLDistributionPoints: []string{crlDP}}, "", db.Revocation{Time: yesterday}))
+	require.NoError(t, churner.db.AddCert(ctx, &x509.Certificate{SerialNumber: sn2, CRLDistributionPoints: []string{crlDP}}, "", db.Revocation{Time: now}))
 
 	missing, err := churner.CheckMissing(ctx)
 	require.NoError(t, err)
 
 	// We should get back sn1 only, which was revoked more than 24 hours ago
 	require.Equal(t, []db.CertMetadata{{
-		CertKey:        db.CertKey{SerialNumber: sn1.Bytes()},
-		RevocationTime: yesterday.Truncate(time.Second),
+		CertKey:              db.CertKey{SerialNumber: sn1.Bytes()},
+		RevocationTime:       yesterday.Truncate(time.Second),
+		CRLDistributionPoint: crlDP,
 	}}, missing)
+
+	// And an alert about it
+	alerts := notifier.Alerts()
+	require.Len(t, alerts, 1)
+	require.Equal(t, "churner", alerts[0].Source)
+	require.Equal(t, []string{db.CertKey{SerialNumber: sn1.Bytes()}.SerialString()}, alerts[0].Serials)
 }
@@ -0,0 +1,226 @@
+package churner
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/certmagic"
+	"github.com/libdns/libdns"
+	"github.com/libdns/rfc2136"
+	"github.com/libdns/route53"
+	"github.com/mholt/acmez/v3"
+	"github.com/mholt/acmez/v3/acme"
+	"github.com/miekg/dns"
+
+	"github.com/letsencrypt/crl-monitor/cmd"
+)
+
+const (
+	DNSProviderEnv          cmd.EnvVar = "DNS_PROVIDER"
+	RFC2136ServerEnv        cmd.EnvVar = "RFC2136_SERVER"
+	RFC2136KeyNameEnv       cmd.EnvVar = "RFC2136_TSIG_KEY_NAME"
+	RFC2136KeySecretEnv     cmd.EnvVar = "RFC2136_TSIG_SECRET"
+	RFC2136KeyAlgEnv        cmd.EnvVar = "RFC2136_TSIG_ALGORITHM"
+	PropagationDelayEnv     cmd.EnvVar = "DNS_PROPAGATION_DELAY"
+	defaultDNSProvider                 = "route53"
+	defaultPropagationDelay            = 60 * time.Second // Route53 docs say 60 seconds in normal conditions
+	defaultTSIGAlgorithm               = "hmac-sha256"
+)
+
+// dns01SolverFromEnv returns a DNS-01 solver using the DNS provider picked by
+// DNS_PROVIDER: "route53" (the default), "rfc2136" or "memory". The memory
+// provider publishes nothing, so it is refused unless acmeDirectory is a
+// local test CA.
+func dns01SolverFromEnv(baseDomain, acmeDirectory string) (acmez.Solver, error) {
+	propagationDelay := defaultPropagationDelay
+	if delay, ok := PropagationDelayEnv.LookupEnv(); ok {
+		var err error
+		propagationDelay, err = time.ParseDuration(delay)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", PropagationDelayEnv, err)
+		}
+	}
+
+	provider, ok := DNSProviderEnv.LookupEnv()
+	if !ok {
+		provider = defaultDNSProvider
+	}
+
+	switch provider {
+	case "route53":
+		return NewDNS01Solver(&route53.Provider{}, propagationDelay), nil
+	case "rfc2136":
+		rfc2136Provider, err := rfc2136ProviderFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return NewDNS01Solver(rfc2136Provider, propagationDelay), nil
+	case "memory":
+		if !isLocalCA(acmeDirectory) {
+			return nil, fmt.Errorf("%s=memory publishes no records, so can only be used with a local test CA, not %s %q", DNSProviderEnv, ACMEDirectoryEnv, acmeDirectory)
+		}
+		return &ZoneDNS01Solver{Provider: &MemoryDNSProvider{}, Zone: baseDomain}, nil
+	default:
+		return nil, fmt.Errorf("unsupported %s %q", DNSProviderEnv, provider)
+	}
+}
+
+// isLocalCA returns true if the ACME directory URL is on a host that can only
+// be a local test CA, such as Pebble: localhost, a loopback or private IP, a
+// name under the reserved .test or .localhost TLDs, or a name without any
+// dots, such as a docker compose service.
+func isLocalCA(directory string) bool {
+	u, err := url.Parse(directory)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback() || ip.IsPrivate()
+	}
+	return host != "" && (host == "localhost" ||
+		strings.HasSuffix(host, ".localhost") ||
+		strings.HasSuffix(host, ".test") ||
+		!strings.Contains(host, "."))
+}
+
+// rfc2136ProviderFromEnv returns a provider which sends RFC 2136 dynamic
+// updates to RFC2136_SERVER, signed with TSIG.
+func rfc2136ProviderFromEnv() (*rfc2136.Provider, error) {
+	server, ok := RFC2136ServerEnv.LookupEnv()
+	if !ok {
+		return nil, fmt.Errorf("%s is required for the rfc2136 DNS provider", RFC2136ServerEnv)
+	}
+	provider := &rfc2136.Provider{Server: server, KeyAlg: defaultTSIGAlgorithm}
+
+	provider.KeyName, _ = RFC2136KeyNameEnv.LookupEnv()
+	provider.Key, _ = RFC2136KeySecretEnv.LookupEnv()
+	if provider.KeyName == "" || provider.Key == "" {
+		return nil, fmt.Errorf("%s and %s are required for the rfc2136 DNS provider", RFC2136KeyNameEnv, RFC2136KeySecretEnv)
+	}
+	if _, err := base64.StdEncoding.DecodeString(provider.Key); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", RFC2136KeySecretEnv, err)
+	}
+	if algorithm, ok := RFC2136KeyAlgEnv.LookupEnv(); ok {
+		provider.KeyAlg = algorithm
+	}
+	return provider, nil
+}
+
+// NewDNS01Solver returns a solver which creates TXT records with dnsProvider,
+// waiting propagationDelay before checking they are visible.
+func NewDNS01Solver(dnsProvider certmagic.DNSProvider, propagationDelay time.Duration) acmez.Solver {
+	return &certmagic.DNS01Solver{
+		DNSManager: certmagic.DNSManager{
+			DNSProvider:      dnsProvider,
+			PropagationDelay: propagationDelay,
+		},
+	}
+}
+
+// ZoneDNS01Solver creates DNS-01 TXT records with Provider in a fixed Zone.
+// Unlike the solver from NewDNS01Solver, it doesn't look up the zone or wait
+// for the records to propagate, so it works without any real DNS.
+type ZoneDNS01Solver struct {
+	Provider certmagic.DNSProvider
+	Zone     string
+}
+
+func (s *ZoneDNS01Solver) record(challenge acme.Challenge) []libdns.Record {
+	return []libdns.Record{libdns.TXT{
+		Name: libdns.RelativeName(challenge.DNS01TXTRecordName(), s.Zone),
+		Text: challenge.DNS01KeyAuthorization(),
+	}}
+}
+
+func (s *ZoneDNS01Solver) Present(ctx context.Context, challenge acme.Challenge) error {
+	_, err := s.Provider.AppendRecords(ctx, s.Zone, s.record(challenge))
+	return err
+}
+
+func (s *ZoneDNS01Solver) CleanUp(ctx context.Context, challenge acme.Challenge) error {
+	_, err := s.Provider.DeleteRecords(ctx, s.Zone, s.record(challenge))
+	return err
+}
+
+// MemoryDNSProvider is a libdns provider which keeps records in memory, for
+// tests and for driving the churner against a local test CA without any DNS
+// provider credentials.
+type MemoryDNSProvider struct {
+	mu sync.Mutex
+	// zones maps a fully-qualified zone name to its records
+	zones map[string][]libdns.RR
+}
+
+func (p *MemoryDNSProvider) GetRecords(_ context.Context, zone string) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var records []libdns.Record
+	for _, rr := range p.zones[dns.Fqdn(zone)] {
+		record, err := rr.Parse()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (p *MemoryDNSProvider) AppendRecords(_ context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.zones == nil {
+		p.zones = make(map[string][]libdns.RR)
+	}
+	zone = dns.Fqdn(zone)
+	for _, record := range records {
+		p.zones[zone] = append(p.zones[zone], record.RR())
+	}
+	return records, nil
+}
+
+// DeleteRecords deletes records matching the name, and the type, TTL and data
+// if they are set, as the libdns.RecordDeleter contract describes.
+func (p *MemoryDNSProvider) DeleteRecords(_ context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	zone = dns.Fqdn(zone)
+	var deleted []libdns.Record
+	var kept []libdns.RR
+	for _, existing := range p.zones[zone] {
+		matched := false
+		for _, record := range records {
+			if rrMatches(existing, record.RR()) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			kept = append(kept, existing)
+			continue
+		}
+		record, err := existing.Parse()
+		if err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, record)
+	}
+	p.zones[zone] = kept
+	return deleted, nil
+}
+
+func rrMatches(existing, filter libdns.RR) bool {
+	return strings.EqualFold(existing.Name, filter.Name) &&
+		(filter.Type == "" || existing.Type == filter.Type) &&
+		(filter.TTL == 0 || existing.TTL == filter.TTL) &&
+		(filter.Data == "" || existing.Data == filter.Data)
+}
@@ -0,0 +1,203 @@
+package churner
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/certmagic"
+	"github.com/libdns/libdns"
+	"github.com/mholt/acmez/v3/acme"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func testChallenge(name, token string) acme.Challenge {
+	return acme.Challenge{
+		Identifier:       acme.Identifier{Type: "dns", Value: name},
+		Token:            token,
+		KeyAuthorization: token + ".thumbprint",
+	}
+}
+
+func TestZoneDNS01SolverWithMemoryProvider(t *testing.T) {
+	ctx := context.Background()
+	provider := &MemoryDNSProvider{}
+	solver := &ZoneDNS01Solver{Provider: provider, Zone: "revoked.invalid"}
+
+	challenge := testChallenge("a.revoked.invalid", "token-a")
+	require.NoError(t, solver.Present(ctx, challenge))
+	require.NoError(t, solver.Present(ctx, testChallenge("b.revoked.invalid", "token-b")))
+
+	records, err := provider.GetRecords(ctx, "revoked.invalid.")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, libdns.TXT{Name: "_acme-challenge.a", Text: challenge.DNS01KeyAuthorization()}, records[0])
+
+	require.NoError(t, solver.CleanUp(ctx, challenge))
+	records, err = provider.GetRecords(ctx, "revoked.invalid")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, "_acme-challenge.b", records[0].RR().Name)
+}
+
+func TestMemoryDNSProviderDeleteByName(t *testing.T) {
+	ctx := context.Background()
+	provider := &MemoryDNSProvider{}
+
+	_, err := provider.AppendRecords(ctx, "revoked.invalid", []libdns.Record{
+		libdns.TXT{Name: "a", Text: "one"},
+		libdns.TXT{Name: "a", Text: "two"},
+		libdns.TXT{Name: "b", Text: "three"},
+	})
+	require.NoError(t, err)
+
+	// Without data, every record with the name is deleted
+	deleted, err := provider.DeleteRecords(ctx, "revoked.invalid", []libdns.Record{libdns.RR{Name: "a"}})
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+
+	records, err := provider.GetRecords(ctx, "revoked.invalid")
+	require.NoError(t, err)
+	require.Equal(t, []libdns.Record{libdns.TXT{Name: "b", Text: "three"}}, records)
+}
+
+// fakeUpdateServer is a DNS server which applies RFC 2136 updates to the
+// TXT records it holds, rejecting any without a valid TSIG signature
+type fakeUpdateServer struct {
+	mu  sync.Mutex
+	txt map[string][]string
+}
+
+func (s *fakeUpdateServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	reply := new(dns.Msg)
+	reply.SetReply(req)
+	defer func() { _ = w.WriteMsg(reply) }()
+
+	if req.IsTsig() == nil || w.TsigStatus() != nil {
+		reply.Rcode = dns.RcodeRefused
+		return
+	}
+	reply.SetTsig(req.IsTsig().Hdr.Name, req.IsTsig().Algorithm, 300, time.Now().Unix())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rr := range req.Ns {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			reply.Rcode = dns.RcodeNotImplemented
+			return
+		}
+		switch txt.Hdr.Class {
+		case dns.ClassINET:
+			s.txt[txt.Hdr.Name] = append(s.txt[txt.Hdr.Name], txt.Txt...)
+		case dns.ClassNONE:
+			var kept []string
+			for _, value := range s.txt[txt.Hdr.Name] {
+				if value != txt.Txt[0] {
+					kept = append(kept, value)
+				}
+			}
+			s.txt[txt.Hdr.Name] = kept
+		}
+	}
+}
+
+func TestRFC2136ProviderFromEnv(t *testing.T) {
+	ctx := context.Background()
+	keyName := "churner."
+	secret := "c2VjcmV0IGtleSBmb3IgdGVzdGluZyB1cGRhdGVz"
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	handler := &fakeUpdateServer{txt: make(map[string][]string)}
+	server := &dns.Server{
+		Listener:   listener,
+		Handler:    handler,
+		TsigSecret: map[string]string{keyName: secret},
+		// The default accept func rejects everything but queries and notifies
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	t.Setenv(string(RFC2136ServerEnv), listener.Addr().String())
+	t.Setenv(string(RFC2136KeyNameEnv), keyName)
+	t.Setenv(string(RFC2136KeySecretEnv), secret)
+	provider, err := rfc2136ProviderFromEnv()
+	require.NoError(t, err)
+	records := []libdns.Record{libdns.TXT{Name: "_acme-challenge.a", Text: "key authorization digest", TTL: time.Minute}}
+
+	_, err = provider.AppendRecords(ctx, "revoked.invalid", records)
+	require.NoError(t, err)
+	handler.mu.Lock()
+	require.Equal(t, []string{"key authorization digest"}, handler.txt["_acme-challenge.a.revoked.invalid."])
+	handler.mu.Unlock()
+
+	_, err = provider.DeleteRecords(ctx, "revoked.invalid", records)
+	require.NoError(t, err)
+	handler.mu.Lock()
+	require.Empty(t, handler.txt["_acme-challenge.a.revoked.invalid."])
+	handler.mu.Unlock()
+
+	// Updates signed with the wrong key are refused
+	t.Setenv(string(RFC2136KeySecretEnv), "d3Jvbmc=")
+	provider, err = rfc2136ProviderFromEnv()
+	require.NoError(t, err)
+	_, err = provider.AppendRecords(ctx, "revoked.invalid", records)
+	require.Error(t, err)
+}
+
+func TestIsLocalCA(t *testing.T) {
+	for _, directory := range []string{
+		"https://localhost:14000/dir",
+		"http://127.0.0.1:4001/directory",
+		"https://[::1]:14000/dir",
+		"https://10.77.77.77/dir",
+		"https://pebble:14000/dir",
+		"https://ca.test/dir",
+	} {
+		require.True(t, isLocalCA(directory), directory)
+	}
+	for _, directory := range []string{
+		"https://acme-v02.api.letsencrypt.org/directory",
+		"https://acme-staging-v02.api.letsencrypt.org/directory",
+		"https://8.8.8.8/dir",
+		"not a url\x00",
+		"",
+	} {
+		require.False(t, isLocalCA(directory), directory)
+	}
+}
+
+func TestDNS01SolverFromEnv(t *testing.T) {
+	t.Setenv(string(DNSProviderEnv), "memory")
+	solver, err := dns01SolverFromEnv("revoked.invalid", "https://localhost:14000/dir")
+	require.NoError(t, err)
+	require.IsType(t, &ZoneDNS01Solver{}, solver)
+
+	// The memory provider can't be used with a real CA
+	_, err = dns01SolverFromEnv("revoked.invalid", "https://acme-v02.api.letsencrypt.org/directory")
+	require.ErrorContains(t, err, "local test CA")
+
+	t.Setenv(string(DNSProviderEnv), "rfc2136")
+	_, err = dns01SolverFromEnv("revoked.invalid", "https://acme-v02.api.letsencrypt.org/directory")
+	require.ErrorContains(t, err, string(RFC2136ServerEnv))
+
+	t.Setenv(string(RFC2136ServerEnv), "127.0.0.1:53")
+	t.Setenv(string(RFC2136KeyNameEnv), "churner")
+	_, err = dns01SolverFromEnv("revoked.invalid", "https://acme-v02.api.letsencrypt.org/directory")
+	require.ErrorContains(t, err, string(RFC2136KeySecretEnv))
+
+	t.Setenv(string(RFC2136KeySecretEnv), "c2VjcmV0")
+	t.Setenv(string(PropagationDelayEnv), "5s")
+	solver, err = dns01SolverFromEnv("revoked.invalid", "https://acme-v02.api.letsencrypt.org/directory")
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, solver.(*certmagic.DNS01Solver).PropagationDelay)
+
+	t.Setenv(string(DNSProviderEnv), "bind")
+	_, err = dns01SolverFromEnv("revoked.invalid", "https://acme-v02.api.letsencrypt.org/directory")
+	require.ErrorContains(t, err, "unsupported")
+}
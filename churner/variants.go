@@ -0,0 +1,137 @@
+package churner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	mathrand "math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// KeyType is the kind of key a certificate variant is issued for.
+type KeyType string
+
+const (
+	P256    KeyType = "p256"
+	P384    KeyType = "p384"
+	RSA2048 KeyType = "rsa2048"
+	RSA3072 KeyType = "rsa3072"
+	RSA4096 KeyType = "rsa4096"
+	// Random is P256 or RSA2048, picked at random for each certificate, which
+	// is what the churner issued before it had variants.
+	Random KeyType = "random"
+)
+
+// DefaultVariants are issued on each run if CHURN_VARIANTS is unset. It's a
+// single certificate, as before variants, so that the load on the CA only
+// grows when more are asked for.
+const DefaultVariants = "random"
+
+// Variant is a kind of certificate the churner issues, so that each issuance
+// path and intermediate has a certificate to find on CRLs.
+type Variant struct {
+	// Name is the variant as configured, such as "p384:sans=3". It's stored
+	// with the serial in the database.
+	Name    string
+	KeyType KeyType
+	// SANs is the number of DNS names on the certificate.
+	SANs int
+	// Wildcard replaces the first name with a wildcard, which can only be
+	// validated with DNS-01.
+	Wildcard bool
+	// Profile is the ACME profile to request. Empty for the CA's default.
+	Profile string
+}
+
+// ParseVariants parses a comma separated list of variants. Each is a key type
+// optionally followed by colon separated options:
+//
+//	sans=N        the number of DNS names, default 1
+//	wildcard      make the first name a wildcard
+//	profile=NAME  request an ACME profile
+//
+// For example "p256,rsa4096:sans=3,p384:wildcard:profile=shortlived".
+func ParseVariants(s string) ([]Variant, error) {
+	var variants []Variant
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		keyType, options, _ := strings.Cut(spec, ":")
+		v := Variant{Name: spec, KeyType: KeyType(keyType), SANs: 1}
+		switch v.KeyType {
+		case P256, P384, RSA2048, RSA3072, RSA4096, Random:
+		default:
+			return nil, fmt.Errorf("variant %q: unknown key type %q", spec, keyType)
+		}
+
+		if options != "" {
+			for _, option := range strings.Split(options, ":") {
+				name, value, _ := strings.Cut(option, "=")
+				switch name {
+				case "sans":
+					sans, err := strconv.Atoi(value)
+					if err != nil || sans < 1 {
+						return nil, fmt.Errorf("variant %q: sans must be a positive number, not %q", spec, value)
+					}
+					v.SANs = sans
+				case "wildcard":
+					v.Wildcard = true
+				case "profile":
+					if value == "" {
+						return nil, fmt.Errorf("variant %q: empty profile", spec)
+					}
+					v.Profile = value
+				default:
+					return nil, fmt.Errorf("variant %q: unknown option %q", spec, option)
+				}
+			}
+		}
+		variants = append(variants, v)
+	}
+
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no variants in %q", s)
+	}
+	return variants, nil
+}
+
+// key generates a new private key of the variant's type.
+func (v Variant) key() (crypto.Signer, error) {
+	switch v.KeyType {
+	case P256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case P384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case RSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case RSA3072:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case RSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case Random:
+		if mathrand.IntN(2) == 0 {
+			return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+	return nil, fmt.Errorf("unknown key type %q", v.KeyType)
+}
+
+// domains picks the names to include on the variant's certificate.
+func (v Variant) domains(baseDomain string) []string {
+	var domains []string
+	for range v.SANs {
+		domains = append(domains, randDomains(baseDomain)...)
+	}
+	if v.Wildcard {
+		domains[0] = "*." + domains[0]
+	}
+	return domains
+}
@@ -0,0 +1,75 @@
+package churner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVariants(t *testing.T) {
+	variants, err := ParseVariants("p256, rsa4096:sans=3,p384:wildcard:profile=shortlived")
+	require.NoError(t, err)
+	require.Equal(t, []Variant{
+		{Name: "p256", KeyType: P256, SANs: 1},
+		{Name: "rsa4096:sans=3", KeyType: RSA4096, SANs: 3},
+		{Name: "p384:wildcard:profile=shortlived", KeyType: P384, SANs: 1, Wildcard: true, Profile: "shortlived"},
+	}, variants)
+
+	variants, err = ParseVariants(DefaultVariants)
+	require.NoError(t, err)
+	require.Equal(t, []Variant{{Name: "random", KeyType: Random, SANs: 1}}, variants)
+
+	for _, bad := range []string{"", "p521", "p256:sans=0", "p256:sans=x", "p256:profile=", "p256:ocsp"} {
+		_, err := ParseVariants(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestVariantKey(t *testing.T) {
+	for _, tt := range []struct {
+		keyType KeyType
+		check   func(t *testing.T, key any)
+	}{
+		{P256, func(t *testing.T, key any) { require.Equal(t, elliptic.P256(), key.(*ecdsa.PrivateKey).Curve) }},
+		{P384, func(t *testing.T, key any) { require.Equal(t, elliptic.P384(), key.(*ecdsa.PrivateKey).Curve) }},
+		{RSA2048, func(t *testing.T, key any) { require.Equal(t, 2048, key.(*rsa.PrivateKey).N.BitLen()) }},
+		{RSA3072, func(t *testing.T, key any) { require.Equal(t, 3072, key.(*rsa.PrivateKey).N.BitLen()) }},
+		{Random, func(t *testing.T, key any) {
+			switch k := key.(type) {
+			case *ecdsa.PrivateKey:
+				require.Equal(t, elliptic.P256(), k.Curve)
+			case *rsa.PrivateKey:
+				require.Equal(t, 2048, k.N.BitLen())
+			default:
+				require.Failf(t, "unexpected key type", "%T", key)
+			}
+		}},
+	} {
+		t.Run(string(tt.keyType), func(t *testing.T) {
+			key, err := Variant{KeyType: tt.keyType}.key()
+			require.NoError(t, err)
+			tt.check(t, key)
+		})
+	}
+}
+
+func TestVariantDomains(t *testing.T) {
+	base := "revoked.invalid"
+
+	domains := Variant{SANs: 3}.domains(base)
+	require.Len(t, domains, 3)
+	for _, domain := range domains {
+		require.True(t, strings.HasSuffix(domain, "."+base))
+		require.False(t, strings.HasPrefix(domain, "*."))
+	}
+	require.NotEqual(t, domains[0], domains[1])
+
+	domains = Variant{SANs: 2, Wildcard: true}.domains(base)
+	require.Len(t, domains, 2)
+	require.True(t, strings.HasPrefix(domains[0], "*."))
+	require.False(t, strings.HasPrefix(domains[1], "*."))
+}
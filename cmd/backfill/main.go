@@ -0,0 +1,52 @@
+// Command backfill adds the pending partition to unseen certificates stored
+// before it was recorded
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/letsencrypt/crl-monitor/db"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -table TABLE [-endpoint URL]\n", os.Args[0])
+		fmt.Fprint(flag.CommandLine.Output(), `
+Adds the PT attribute to rows of the unseen certificates table stored before
+it was written, so that the churner's query of the PT-RT-index finds them.
+Rows without a CRLDistributionPoint are left alone, as the checker can't see
+them on any shard.
+
+This scans the whole table, so only needs running once, when upgrading.
+
+Examples:
+  Backfill the table in DynamoDB Local.
+    backfill -table unseen-certificates -endpoint http://localhost:8000
+`)
+		fmt.Fprintln(flag.CommandLine.Output(), "Options:")
+		flag.PrintDefaults()
+	}
+	flagTable := flag.String("table", "", "DynamoDB table of unseen certificates")
+	flagEndpoint := flag.String("endpoint", "", "DynamoDB endpoint, if not the default for the AWS region")
+	flag.Parse()
+	if *flagTable == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	database, err := db.New(ctx, *flagTable, *flagEndpoint)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	updated, err := database.BackfillPending(ctx)
+	if err != nil {
+		log.Fatalf("backfilled %d rows before failing: %v", updated, err)
+	}
+	fmt.Printf("backfilled %d rows\n", updated)
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 
 	"github.com/letsencrypt/crl-monitor/checker"
 	"github.com/letsencrypt/crl-monitor/cmd"
+	"github.com/letsencrypt/crl-monitor/logging"
 )
 
 const (
@@ -15,6 +17,7 @@ const (
 )
 
 func main() {
+	logging.Setup()
 	bucket := S3CRLBucket.MustRead("S3 CRL bucket name")
 	object := S3CRLObject.MustRead("S3 Object path to CRL file")
 	version, hasVersion := S3CRLVersion.LookupEnv()
@@ -32,8 +35,9 @@ func main() {
 		optionalVersion = &version
 	}
 
+	// Check logs its own errors, with their severity
 	err = c.Check(ctx, bucket, object, optionalVersion)
 	if err != nil {
-		log.Printf("error checking CRL %s: %v", object, err)
+		os.Exit(1)
 	}
 }
@@ -3,13 +3,16 @@ package main
 import (
 	"context"
 	"log"
+	"log/slog"
 	"os"
 	"time"
 
 	"github.com/letsencrypt/crl-monitor/churner"
+	"github.com/letsencrypt/crl-monitor/logging"
 )
 
 func main() {
+	logging.Setup()
 	ctx := context.Background()
 
 	c, err := churner.NewFromEnv(ctx)
@@ -32,9 +35,12 @@ func main() {
 		log.Fatalf("Error checking for missing certs: %v", err)
 	}
 	if len(missing) != 0 {
-		log.Print("Certificates didn't appear in CRL in time:")
 		for _, missed := range missing {
-			log.Printf("Cert serial %x revoked at %s (%s ago)", missed.SerialNumber, missed.RevocationTime, time.Since(missed.RevocationTime))
+			slog.Error("certificate didn't appear in CRL in time",
+				logging.Serial, missed.SerialString(),
+				logging.IDP, missed.CRLDistributionPoint,
+				"revocationTime", missed.RevocationTime,
+				"age", time.Since(missed.RevocationTime).String())
 		}
 		os.Exit(1)
 	}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/letsencrypt/boulder/core"
+)
+
+// Issuer describes where one of Let's Encrypt's intermediates publishes its CRLs.
+type Issuer struct {
+	Prefix      string
+	Short       string
+	Environment string
+	Bucket      string
+}
+
+var (
+	// Issuers is keyed by the issuer's CRL URL, without a shard.
+	//
+	// It is possible to determine this information at runtime, by using the AIA cert to determine
+	// the CRL prefix, which is sha1(Issuer's Subject)[..7]. But this list changes slowly, so it's
+	// not really worth the code.
+	Issuers = map[string]Issuer{
+		"http://r3.c.lencr.org/":      {Prefix: "20506757847264211", Short: "r3", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://e1.c.lencr.org/":      {Prefix: "67430855296768143", Short: "e1", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://e5.c.lencr.org/":      {Prefix: "8463769016270244", Short: "e5", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://e6.c.lencr.org/":      {Prefix: "59807078151219433", Short: "e6", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://e7.c.lencr.org/":      {Prefix: "60964145547838761", Short: "e7", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://e8.c.lencr.org/":      {Prefix: "39409295459939154", Short: "e8", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://e9.c.lencr.org/":      {Prefix: "31110014771015909", Short: "e9", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://r10.c.lencr.org/":     {Prefix: "29572344840711535", Short: "r10", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://r11.c.lencr.org/":     {Prefix: "7409306942694595", Short: "r11", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://r12.c.lencr.org/":     {Prefix: "58036202463912065", Short: "r12", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://r13.c.lencr.org/":     {Prefix: "32259589997855422", Short: "r13", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://r14.c.lencr.org/":     {Prefix: "26458629343095443", Short: "r14", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://ye1.c.lencr.org/":     {Prefix: "15121864070385704", Short: "ye1", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://ye2.c.lencr.org/":     {Prefix: "11216248321241435", Short: "ye2", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://ye3.c.lencr.org/":     {Prefix: "25784596091186334", Short: "ye3", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://yr1.c.lencr.org/":     {Prefix: "27437271743860294", Short: "yr1", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://yr2.c.lencr.org/":     {Prefix: "29076392620644760", Short: "yr2", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://yr3.c.lencr.org/":     {Prefix: "8671320929137997", Short: "yr3", Environment: "prod", Bucket: "le-crl-prod"},
+		"http://stg-e1.c.lencr.org/":  {Prefix: "4169287449788112", Short: "stg-e1", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-r3.c.lencr.org/":  {Prefix: "58367272336442518", Short: "stg-r3", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-e5.c.lencr.org/":  {Prefix: "15225348384016519", Short: "stg-e5", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-e6.c.lencr.org/":  {Prefix: "17820861098434744", Short: "stg-e6", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-e7.c.lencr.org/":  {Prefix: "47232933130476073", Short: "stg-e7", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-e8.c.lencr.org/":  {Prefix: "46976321144248399", Short: "stg-e8", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-e9.c.lencr.org/":  {Prefix: "19871214657240562", Short: "stg-e9", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-r10.c.lencr.org/": {Prefix: "68020589961194420", Short: "stg-r10", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-r11.c.lencr.org/": {Prefix: "28857625597875079", Short: "stg-r11", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-r12.c.lencr.org/": {Prefix: "35768502929761868", Short: "stg-r12", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-r13.c.lencr.org/": {Prefix: "34377515378669764", Short: "stg-r13", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-r14.c.lencr.org/": {Prefix: "50213926740952395", Short: "stg-r14", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-ye1.c.lencr.org/": {Prefix: "51816688251801090", Short: "stg-ye1", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-ye2.c.lencr.org/": {Prefix: "48471306034107219", Short: "stg-ye2", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-ye3.c.lencr.org/": {Prefix: "38622039212951449", Short: "stg-ye3", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-yr1.c.lencr.org/": {Prefix: "70136346555307663", Short: "stg-yr1", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-yr2.c.lencr.org/": {Prefix: "27211217977121518", Short: "stg-yr2", Environment: "stg", Bucket: "le-crl-stg"},
+		"http://stg-yr3.c.lencr.org/": {Prefix: "8473284859346140", Short: "stg-yr3", Environment: "stg", Bucket: "le-crl-stg"},
+	}
+)
+
+// LoadIssuers loads a colon (:) separated list of paths to PEM-formatted
+// issuer certificates.
+func LoadIssuers(paths string) ([]*x509.Certificate, error) {
+	var issuers []*x509.Certificate
+	for _, path := range strings.Split(paths, ":") {
+		issuer, err := core.LoadCert(path)
+		if err != nil {
+			return nil, fmt.Errorf("error loading issuer certificate: %v", err)
+		}
+		issuers = append(issuers, issuer)
+	}
+	return issuers, nil
+}
+
+// ShortNames maps the Prefix of each of the Issuers to its Short name, as
+// storage.NewFilesystem takes them.
+func ShortNames() map[string]string {
+	shortNames := make(map[string]string, len(Issuers))
+	for _, issuer := range Issuers {
+		shortNames[issuer.Prefix] = issuer.Short
+	}
+	return shortNames
+}
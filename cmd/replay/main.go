@@ -0,0 +1,124 @@
+// Command replay runs the checker over an entire CRL history dumped by scraper
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/letsencrypt/crl-monitor/checker"
+	"github.com/letsencrypt/crl-monitor/checker/earlyremoval"
+	"github.com/letsencrypt/crl-monitor/checker/expiry"
+	"github.com/letsencrypt/crl-monitor/cmd"
+	"github.com/letsencrypt/crl-monitor/storage"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -issuers PATHS [-boulder-base-url URL] [-max-fetch INT] [-fetch-concurrency INT] [-readd-window INT] DIR\n", os.Args[0])
+		fmt.Fprint(flag.CommandLine.Output(), `
+Replays the checker over a directory of CRL versions written by scraper. For
+every shard, each version is linted and each pair of consecutive versions is
+checked for CRL number and thisUpdate ordering, for early removal, for entries
+whose revocation time or reason changed, and for serials re-added after being
+removed. Every violation found is printed, along with the version IDs involved.
+Transient errors, such as failures to look up certificates, are printed
+separately, as they mean a check couldn't complete rather than that a CRL is
+wrong. Either makes replay exit non-zero.
+
+CRLs are not checked for being too old, as the versions being replayed
+are historical.
+
+Early removal needs to look up certificates from Boulder, so it is skipped
+unless -boulder-base-url is given.
+
+Examples:
+  Replay a corpus of production CRLs.
+    replay -issuers checker/testdata/r13.pem:checker/testdata/e8.pem \
+      -boulder-base-url https://acme-v02.api.letsencrypt.org/get/cert \
+      ./corpus
+`)
+		fmt.Fprintln(flag.CommandLine.Output(), "Options:")
+		flag.PrintDefaults()
+	}
+	flagIssuers := flag.String("issuers", "", "colon (:) separated list of paths to PEM-formatted CRL issuer certificates")
+	flagBoulderBaseURL := flag.String("boulder-base-url", "", "Boulder endpoint to fetch certificate info from, e.g. https://boulder.example.com/get/certinfo")
+	flagMaxFetch := flag.Int("max-fetch", 0, "maximum number of removed serials to look up per pair of versions (default all)")
+	flagFetchConcurrency := flag.Int("fetch-concurrency", 10, "number of certificates to look up at once")
+	flagReaddWindow := flag.Int("readd-window", 4, "number of earlier versions to look for removed serials in, when checking for re-added serials")
+	flag.Parse()
+	if flag.NArg() == 0 || *flagIssuers == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	dir := flag.Args()[0]
+
+	issuers, err := cmd.LoadIssuers(*flagIssuers)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fs, err := storage.NewFilesystem(dir, cmd.ShortNames())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var fetcher earlyremoval.Fetcher
+	if *flagBoulderBaseURL != "" {
+		fetcher = &expiry.BoulderAPIFetcher{BaseURL: *flagBoulderBaseURL}
+	}
+
+	// The checker's age limit is relative to the current time, which doesn't
+	// make sense for historical CRLs, so effectively disable it. There's no
+	// database, so no revocation times to compare to either.
+	c := checker.New(checker.Config{
+		Storage:          fs,
+		Fetcher:          fetcher,
+		MaxFetch:         *flagMaxFetch,
+		FetchConcurrency: *flagFetchConcurrency,
+		AgeLimit:         time.Duration(math.MaxInt64),
+		Issuers:          issuers,
+		ReaddWindow:      *flagReaddWindow,
+	})
+
+	violations, transient, err := run(context.Background(), c, fs)
+	for _, violation := range violations {
+		fmt.Println(violation)
+	}
+	for _, incomplete := range transient {
+		fmt.Println("transient error:", incomplete)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d violations found, %d checks incomplete\n", len(violations), len(transient))
+	if len(violations) != 0 || len(transient) != 0 {
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, c *checker.Checker, fs *storage.Filesystem) ([]checker.Violation, []checker.Violation, error) {
+	var violations, transient []checker.Violation
+	for _, object := range fs.Objects() {
+		versions, err := fs.Versions(storage.Key{Object: object})
+		if err != nil {
+			return violations, transient, err
+		}
+		// Versions are listed newest first, but we replay them in order
+		slices.Reverse(versions)
+
+		log.Printf("replaying %d versions of %s", len(versions), object)
+		found, incomplete, err := c.Replay(ctx, "", object, versions)
+		violations = append(violations, found...)
+		transient = append(transient, incomplete...)
+		if err != nil {
+			return violations, transient, fmt.Errorf("replaying %s: %w", object, err)
+		}
+	}
+	return violations, transient, nil
+}
@@ -0,0 +1,75 @@
+// Command shards checks the current versions of all of an issuer's CRL shards
+// against each other
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/letsencrypt/crl-monitor/checker"
+	"github.com/letsencrypt/crl-monitor/cmd"
+	"github.com/letsencrypt/crl-monitor/storage"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -issuers PATHS (-bucket BUCKET | -dir DIR)\n", os.Args[0])
+		fmt.Fprint(flag.CommandLine.Output(), `
+Loads the current version of every CRL shard for each issuer, and reports
+serials that appear on more than one shard, and shards whose
+IssuingDistributionPoint doesn't match their object name.
+
+Shards are read from S3, or from a directory written by scraper.
+
+Examples:
+  Check the shards of production CRLs in S3.
+    shards -issuers checker/testdata/r13.pem:checker/testdata/e8.pem -bucket BUCKET
+`)
+		fmt.Fprintln(flag.CommandLine.Output(), "Options:")
+		flag.PrintDefaults()
+	}
+	flagIssuers := flag.String("issuers", "", "colon (:) separated list of paths to PEM-formatted CRL issuer certificates")
+	flagBucket := flag.String("bucket", "", "S3 bucket to read CRL shards from")
+	flagDir := flag.String("dir", "", "directory of CRL versions written by scraper to read CRL shards from")
+	flag.Parse()
+	if *flagIssuers == "" || (*flagBucket == "") == (*flagDir == "") {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	issuers, err := cmd.LoadIssuers(*flagIssuers)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	var store checker.Storage
+	if *flagDir != "" {
+		fs, err := storage.NewFilesystem(*flagDir, cmd.ShortNames())
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = fs
+	} else {
+		store = storage.New(ctx)
+	}
+
+	// Only the current version of each shard is read, so there's no early
+	// removal to check, and no need for a database.
+	c := checker.New(checker.Config{
+		Storage:  store,
+		AgeLimit: 24 * time.Hour,
+		Issuers:  issuers,
+	})
+
+	err = c.CheckAllShards(ctx, *flagBucket)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("no problems found")
+}
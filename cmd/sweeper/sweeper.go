@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/letsencrypt/crl-monitor/checker"
+	"github.com/letsencrypt/crl-monitor/cmd"
+	"github.com/letsencrypt/crl-monitor/logging"
+)
+
+const S3CRLBucket cmd.EnvVar = "S3_CRL_BUCKET"
+
+func main() {
+	logging.Setup()
+	bucket := S3CRLBucket.MustRead("S3 CRL bucket name")
+
+	ctx := context.Background()
+
+	s, err := checker.NewSweeperFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("error creating sweeper: %v", err)
+	}
+
+	// Sweep logs its own errors, with their severity
+	err = s.Sweep(ctx, bucket, time.Now())
+	if err != nil {
+		os.Exit(1)
+	}
+}
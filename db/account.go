@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrNoAccount is returned by GetAccount when no account is stored for a
+// directory.
+var ErrNoAccount = errors.New("no ACME account stored")
+
+// Account is an ACME account, stored so the churner can reuse it between runs
+// rather than registering a new one each time.
+type Account struct {
+	// Directory is the URL of the ACME directory the account is registered
+	// with, and the primary key of the AccountTable.
+	Directory string `dynamodbav:"Directory"`
+	// Location is the account's URL.
+	Location string `dynamodbav:"Location"`
+	// Key is the account's private key, PKCS#8 DER encoded.
+	Key []byte `dynamodbav:"Key"`
+}
+
+// GetAccount returns the account stored for an ACME directory, or
+// ErrNoAccount if there isn't one.
+func (db *Database) GetAccount(ctx context.Context, directory string) (*Account, error) {
+	if db.AccountTable == "" {
+		return nil, fmt.Errorf("no account table configured")
+	}
+
+	resp, err := db.Dynamo.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(db.AccountTable),
+		Key:            map[string]types.AttributeValue{"Directory": &types.AttributeValueMemberS{Value: directory}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting account for %s: %w", directory, err)
+	}
+	if resp.Item == nil {
+		return nil, ErrNoAccount
+	}
+
+	var account Account
+	err = attributevalue.UnmarshalMap(resp.Item, &account)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling account for %s: %w", directory, err)
+	}
+	return &account, nil
+}
+
+// PutAccount stores an account, replacing any other for the same directory.
+func (db *Database) PutAccount(ctx context.Context, account *Account) error {
+	if db.AccountTable == "" {
+		return fmt.Errorf("no account table configured")
+	}
+
+	item, err := attributevalue.MarshalMap(account)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Dynamo.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(db.AccountTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("putting account for %s: %w", account.Directory, err)
+	}
+	return nil
+}
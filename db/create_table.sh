@@ -3,8 +3,49 @@
 aws dynamodb \
 	--endpoint-url "http://localhost:8000" \
        	create-table --table-name "unseen-certificates" \
-       	--attribute-definitions AttributeName=SN,AttributeType=B \
+       	--attribute-definitions \
+		AttributeName=SN,AttributeType=B \
+		AttributeName=DP,AttributeType=S \
+		AttributeName=RT,AttributeType=N \
+		AttributeName=PT,AttributeType=S \
         --key-schema AttributeName=SN,KeyType=HASH \
+	--global-secondary-indexes \
+		'IndexName=DP-RT-index,KeySchema=[{AttributeName=DP,KeyType=HASH},{AttributeName=RT,KeyType=RANGE}],Projection={ProjectionType=ALL},ProvisionedThroughput={ReadCapacityUnits=1,WriteCapacityUnits=1}' \
+		'IndexName=PT-RT-index,KeySchema=[{AttributeName=PT,KeyType=HASH},{AttributeName=RT,KeyType=RANGE}],Projection={ProjectionType=ALL},ProvisionedThroughput={ReadCapacityUnits=1,WriteCapacityUnits=1}' \
 	--provisioned-throughput ReadCapacityUnits=1,WriteCapacityUnits=1 \
-	--table-class STANDARD \
+	--table-class STANDARD
 
+aws dynamodb \
+	--endpoint-url "http://localhost:8000" \
+	create-table --table-name "publication-latency" \
+	--attribute-definitions AttributeName=Day,AttributeType=S \
+	--key-schema AttributeName=Day,KeyType=HASH \
+	--provisioned-throughput ReadCapacityUnits=1,WriteCapacityUnits=1 \
+	--table-class STANDARD
+
+aws dynamodb \
+	--endpoint-url "http://localhost:8000" \
+	create-table --table-name "churner-accounts" \
+	--attribute-definitions AttributeName=Directory,AttributeType=S \
+	--key-schema AttributeName=Directory,KeyType=HASH \
+	--provisioned-throughput ReadCapacityUnits=1,WriteCapacityUnits=1 \
+	--table-class STANDARD
+
+aws dynamodb \
+	--endpoint-url "http://localhost:8000" \
+	create-table --table-name "certificate-expiry" \
+	--attribute-definitions AttributeName=SN,AttributeType=B \
+	--key-schema AttributeName=SN,KeyType=HASH \
+	--provisioned-throughput ReadCapacityUnits=1,WriteCapacityUnits=1 \
+	--table-class STANDARD
+
+# Without time to live enabled, DynamoDB keeps every entry forever
+aws dynamodb \
+	--endpoint-url "http://localhost:8000" \
+	update-time-to-live --table-name "certificate-expiry" \
+	--time-to-live-specification Enabled=true,AttributeName=TTL
+
+aws dynamodb \
+	--endpoint-url "http://localhost:8000" \
+	update-time-to-live --table-name "publication-latency" \
+	--time-to-live-specification Enabled=true,AttributeName=TTL
@@ -3,9 +3,13 @@ package db
 import (
 	"context"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"math/big"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -18,13 +22,54 @@ import (
 // ddb is fulfilled by a dynamodb.Client and is used for mocking in tests.
 type ddb interface {
 	BatchWriteItem(context.Context, *dynamodb.BatchWriteItemInput, ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
 	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(context.Context, *dynamodb.QueryInput, ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
 	Scan(context.Context, *dynamodb.ScanInput, ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	UpdateItem(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+}
+
+// IDPIndex is the name of the global secondary index keyed on the
+// CRLDistributionPoint (DP) with the RevocationTime (RT) as its sort key.
+const IDPIndex = "DP-RT-index"
+
+// RevocationTimeIndex is the name of the global secondary index keyed on the
+// pending partition (PT) with the RevocationTime (RT) as its sort key. Every
+// certificate stored by AddCert is in one of pendingPartitions partitions, so
+// the index holds all of them in order of revocation, without every write
+// going to the same partition.
+const RevocationTimeIndex = "PT-RT-index"
+
+// pendingPartitions is how many values of PT certificates are spread over.
+const pendingPartitions = 8
+
+// pendingPartition returns the PT of the n'th partition, "pending#<n>".
+func pendingPartition(n int) string {
+	return fmt.Sprintf("pending#%d", n)
+}
+
+// partitionFor returns the PT of a certificate stored by AddCert, picked by the
+// last byte of its serial, which is random. Rows stored before PT was written
+// need it added by BackfillPending, or they aren't in the RevocationTimeIndex.
+func partitionFor(ck CertKey) string {
+	var last byte
+	if len(ck.SerialNumber) > 0 {
+		last = ck.SerialNumber[len(ck.SerialNumber)-1]
+	}
+	return pendingPartition(int(last) % pendingPartitions)
 }
 
 type Database struct {
-	Table  string
-	Dynamo ddb
+	Table string
+	// LatencyTable holds daily summaries of publication latency, keyed on the
+	// Day. If empty, latencies aren't recorded.
+	LatencyTable string
+	// AccountTable holds the churner's ACME accounts, keyed on the Directory.
+	AccountTable string
+	// ExpiryTable caches the NotAfter of certificates looked up by the
+	// checker, keyed on the serial (SN).
+	ExpiryTable string
+	Dynamo      ddb
 }
 
 func New(ctx context.Context, table, dynamoEndpoint string) (*Database, error) {
@@ -44,11 +89,43 @@ func New(ctx context.Context, table, dynamoEndpoint string) (*Database, error) {
 }
 
 // CertMetadata is the entire set of attributes stored in Dynamo.
-// That is the CertKey plus the revocation time today.
+// That is the CertKey plus the revocation time, CRLDistributionPoint,
+// issuance time, and how it was revoked today.
 type CertMetadata struct {
 	CertKey
 	RevocationTime       time.Time `dynamodbav:"RT,unixtime"`
 	CRLDistributionPoint string    `dynamodbav:"DP,string,omitempty"`
+	// NotBefore is the certificate's issuance time. It is zero for entries
+	// stored before it was recorded.
+	NotBefore time.Time `dynamodbav:"NB,unixtime,omitempty"`
+	// RevocationMethod is empty for entries stored before it was recorded,
+	// which were all revoked by their account with cessationOfOperation.
+	RevocationMethod RevocationMethod `dynamodbav:"RM,omitempty"`
+	// ReasonCode is the reason code requested in the revocation.
+	ReasonCode int `dynamodbav:"RC,omitempty"`
+	// Variant is the kind of certificate the churner issued, such as
+	// "p384:sans=3". It is empty for entries stored before it was recorded.
+	Variant string `dynamodbav:"V,omitempty"`
+}
+
+// RevocationMethod is how the churner authenticated a revocation request.
+type RevocationMethod string
+
+const (
+	// RevokedByAccount requests are signed by the account which issued the
+	// certificate, and the CRL entry has the requested reason.
+	RevokedByAccount RevocationMethod = "account"
+	// RevokedByCertKey requests are signed by the certificate's own key. The
+	// CA treats that as proof of compromise, so the CRL entry has the
+	// keyCompromise reason whatever was requested.
+	RevokedByCertKey RevocationMethod = "certkey"
+)
+
+// Revocation is when and how a certificate was revoked.
+type Revocation struct {
+	Time       time.Time
+	Method     RevocationMethod
+	ReasonCode int
 }
 
 // CertKey is the DynamoDB primary key, which is the serial number.
@@ -67,24 +144,28 @@ func (ck CertKey) SerialString() string {
 }
 
 // AddCert inserts the metadata for monitoring
-func (db *Database) AddCert(ctx context.Context, certificate *x509.Certificate, revocationTime time.Time) error {
-	var crlDistributionPoint string
-	// TODO: Once all issued certificates have a CRLDistributionPoint, error out when
-	// the extension is absent.
-	if len(certificate.CRLDistributionPoints) > 0 {
-		crlDistributionPoint = certificate.CRLDistributionPoints[0]
-	}
-	if len(certificate.CRLDistributionPoints) > 1 {
-		return fmt.Errorf("too many CRLDistributionPoints in certificate: %d", len(certificate.CRLDistributionPoints))
-	}
-	item, err := attributevalue.MarshalMap(CertMetadata{
-		CertKey:              NewCertKey(certificate.SerialNumber),
-		RevocationTime:       revocationTime,
-		CRLDistributionPoint: crlDistributionPoint,
+func (db *Database) AddCert(ctx context.Context, certificate *x509.Certificate, variant string, revocation Revocation) error {
+	// The checker only looks for certificates under their CRLDistributionPoint,
+	// so one without would never be seen
+	if len(certificate.CRLDistributionPoints) != 1 {
+		return fmt.Errorf("expected exactly one CRLDistributionPoint in certificate, got %d", len(certificate.CRLDistributionPoints))
+	}
+	certKey := NewCertKey(certificate.SerialNumber)
+	item, err := attributevalue.MarshalMapWithOptions(CertMetadata{
+		CertKey:              certKey,
+		RevocationTime:       revocation.Time,
+		CRLDistributionPoint: certificate.CRLDistributionPoints[0],
+		NotBefore:            certificate.NotBefore,
+		RevocationMethod:     revocation.Method,
+		ReasonCode:           revocation.ReasonCode,
+		Variant:              variant,
+	}, func(o *attributevalue.EncoderOptions) {
+		o.OmitEmptyTime = true
 	})
 	if err != nil {
 		return err
 	}
+	item["PT"] = &types.AttributeValueMemberS{Value: partitionFor(certKey)}
 
 	_, err = db.Dynamo.PutItem(ctx, &dynamodb.PutItemInput{
 		Item:      item,
@@ -97,32 +178,160 @@ func (db *Database) AddCert(ctx context.Context, certificate *x509.Certificate,
 	return nil
 }
 
-// GetAllCerts returns all the certificates in the DynamoDB.  This set is
-// intended to be much smaller than the set of certificates in a CRL, so it's
-// more efficient to just load the entire set instead of conditional querying.
+// GetCertsRevokedBefore returns the certificates in the DynamoDB which were
+// revoked before the cutoff, by querying each partition of the
+// RevocationTimeIndex rather than scanning the whole table.
 // The map key is the serial's CertKey.SerialString.
-// TODO: This could be more efficient if it was a query over issuer or shard
-// TODO: However, the dataset is small enough to not matter much.
-func (db *Database) GetAllCerts(ctx context.Context) (map[string]CertMetadata, error) {
-	resp, err := db.Dynamo.Scan(ctx, &dynamodb.ScanInput{
-		TableName: &db.Table,
-		Select:    types.SelectAllAttributes,
+func (db *Database) GetCertsRevokedBefore(ctx context.Context, cutoff time.Time) (map[string]CertMetadata, error) {
+	certs := make(map[string]CertMetadata)
+	for n := range pendingPartitions {
+		partition, err := db.query(ctx, &dynamodb.QueryInput{
+			TableName:              &db.Table,
+			IndexName:              aws.String(RevocationTimeIndex),
+			KeyConditionExpression: aws.String("PT = :pt AND RT < :cutoff"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pt":     &types.AttributeValueMemberS{Value: pendingPartition(n)},
+				":cutoff": &types.AttributeValueMemberN{Value: strconv.FormatInt(cutoff.Unix(), 10)},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(certs, partition)
+	}
+	return certs, nil
+}
+
+// GetCertsForIDP returns the certificates whose CRLDistributionPoint is idp,
+// by querying the IDPIndex rather than scanning the whole table.
+// The map key is the serial's CertKey.SerialString.
+func (db *Database) GetCertsForIDP(ctx context.Context, idp string) (map[string]CertMetadata, error) {
+	return db.query(ctx, &dynamodb.QueryInput{
+		TableName:              &db.Table,
+		IndexName:              aws.String(IDPIndex),
+		KeyConditionExpression: aws.String("DP = :dp"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":dp": &types.AttributeValueMemberS{Value: idp},
+		},
+	})
+}
+
+// BackfillPending adds the PT attribute to rows stored before it was written,
+// so that GetCertsRevokedBefore finds them, and returns how many it updated.
+// Rows without a CRLDistributionPoint are left alone, as the checker can't see
+// them on any shard. It scans the whole table, so it's only meant to be run
+// once, by cmd/backfill, when upgrading.
+func (db *Database) BackfillPending(ctx context.Context) (int, error) {
+	legacy, err := db.scan(ctx, &dynamodb.ScanInput{
+		TableName:        &db.Table,
+		FilterExpression: aws.String("attribute_not_exists(PT) AND attribute_exists(DP)"),
 	})
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+
+	updated := 0
+	for _, cert := range legacy {
+		key, err := attributevalue.MarshalMap(cert.CertKey)
+		if err != nil {
+			return updated, err
+		}
+		_, err = db.Dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: &db.Table,
+			Key:       key,
+			// The checker may have deleted the row since the scan, and it
+			// shouldn't come back as an item with nothing but a PT
+			ConditionExpression: aws.String("attribute_exists(SN)"),
+			UpdateExpression:    aws.String("SET PT = :pt"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pt": &types.AttributeValueMemberS{Value: partitionFor(cert.CertKey)},
+			},
+		})
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			continue
+		}
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// query runs a Query to completion, following the LastEvaluatedKey like scan.
+func (db *Database) query(ctx context.Context, input *dynamodb.QueryInput) (map[string]CertMetadata, error) {
+	certs := make(map[string]CertMetadata)
+	for {
+		resp, err := db.Dynamo.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		err = addCerts(certs, resp.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(resp.LastEvaluatedKey) == 0 {
+			return certs, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// scan runs a Scan to completion. A single Scan returns at most 1MB of data,
+// so keep scanning from the LastEvaluatedKey until DynamoDB reports there's
+// nothing left.
+func (db *Database) scan(ctx context.Context, input *dynamodb.ScanInput) (map[string]CertMetadata, error) {
+	certs := make(map[string]CertMetadata)
+	for {
+		resp, err := db.Dynamo.Scan(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		err = addCerts(certs, resp.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(resp.LastEvaluatedKey) == 0 {
+			return certs, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
 	}
+}
 
+// addCerts unmarshals DynamoDB items into certs, keyed by SerialString.
+func addCerts(certs map[string]CertMetadata, items []map[string]types.AttributeValue) error {
 	var certList []CertMetadata
-	err = attributevalue.UnmarshalListOfMaps(resp.Items, &certList)
+	err := attributevalue.UnmarshalListOfMaps(items, &certList)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	certs := make(map[string]CertMetadata, len(certList))
 	for _, cert := range certList {
 		certs[cert.SerialString()] = cert
 	}
-	return certs, nil
+	return nil
+}
+
+// maxBatchWriteItems is the largest number of requests DynamoDB accepts in a
+// single BatchWriteItem call.
+const maxBatchWriteItems = 25
+
+// batchWriteBackoff is the schedule of delays before resubmitting any
+// UnprocessedItems returned by BatchWriteItem. The final value is zero so that
+// we don't sleep before returning an error.
+var batchWriteBackoff = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	400 * time.Millisecond,
+	800 * time.Millisecond,
+	1600 * time.Millisecond,
+	0,
 }
 
 // DeleteSerials takes a list of serials that we've seen in the CRL and thus
@@ -144,11 +353,36 @@ func (db *Database) DeleteSerials(ctx context.Context, serialNumbers [][]byte) e
 		})
 	}
 
-	_, err := db.Dynamo.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
-		RequestItems: map[string][]types.WriteRequest{db.Table: deletes},
-	})
-	if err != nil {
-		return err
+	for batch := range slices.Chunk(deletes, maxBatchWriteItems) {
+		err := db.batchWrite(ctx, batch)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// batchWrite submits a single batch of write requests, resubmitting any
+// UnprocessedItems on a backoff schedule until they have all been processed.
+func (db *Database) batchWrite(ctx context.Context, requests []types.WriteRequest) error {
+	for _, backoff := range batchWriteBackoff {
+		resp, err := db.Dynamo.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{db.Table: requests},
+		})
+		if err != nil {
+			return err
+		}
+
+		requests = resp.UnprocessedItems[db.Table]
+		if len(requests) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	return fmt.Errorf("%d items still unprocessed after %d attempts", len(requests), len(batchWriteBackoff))
+}
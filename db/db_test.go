@@ -9,16 +9,31 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/stretchr/testify/require"
 
 	"github.com/letsencrypt/crl-monitor/db"
 	"github.com/letsencrypt/crl-monitor/db/mock"
 )
 
+// crlDP is the CRLDistributionPoint of certificates whose shard doesn't matter.
+var crlDP = []string{"http://c.example.com/1.crl"}
+
 func TestDatabaseWithMock(t *testing.T) {
 	smoketest(t, mock.NewMockedDB(t))
 }
 
+func TestDatabaseWithPaginatedMock(t *testing.T) {
+	// smoketest inserts 4 entries, so these page sizes split the query into
+	// several pages, including a final partial page.
+	for _, pageSize := range []int{1, 3} {
+		t.Run(fmt.Sprintf("page size %d", pageSize), func(t *testing.T) {
+			smoketest(t, mock.NewPaginatedMockedDB(t, pageSize))
+		})
+	}
+}
+
 // smoketest goes through a set of basic actions ensuring the basics work
 // It gets run with a mocked database and can also be integration tested against
 // the real DynamoDB, or the downloadable version, to ensure they align.
@@ -32,25 +47,26 @@ func smoketest(t *testing.T, handle *db.Database) {
 	int4s := big.NewInt(444444)
 	int60s := big.NewInt(606060)
 	int123 := big.NewInt(123456)
+	dp := "http://c.example.com/1.crl"
 
 	// Insert 4 entries into the database with different serials and revocation times
-	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: int111}, ts1))
-	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: int4s}, ts1))
-	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: int60s}, ts2))
-	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: int123}, ts2))
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: int111, CRLDistributionPoints: []string{dp}}, "", db.Revocation{Time: ts1}))
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: int4s, CRLDistributionPoints: []string{dp}}, "", db.Revocation{Time: ts1}))
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: int60s, CRLDistributionPoints: []string{dp}}, "", db.Revocation{Time: ts2}))
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: int123, CRLDistributionPoints: []string{dp}}, "", db.Revocation{Time: ts2}))
 
 	// Timestamps stored in Dynamo as unix timestamps are truncated to second precision
 	ts1 = ts1.Truncate(time.Second)
 	ts2 = ts2.Truncate(time.Second)
 
-	certs, err := handle.GetAllCerts(ctx)
+	certs, err := handle.GetCertsForIDP(ctx, dp)
 	require.NoError(t, err)
 	require.Len(t, certs, 4)
 	require.Equal(t, certs, map[string]db.CertMetadata{
-		"00000000000000000000000000000000006f": {CertKey: db.CertKey{SerialNumber: int111.Bytes()}, RevocationTime: ts1},
-		"00000000000000000000000000000006c81c": {CertKey: db.CertKey{SerialNumber: int4s.Bytes()}, RevocationTime: ts1},
-		"000000000000000000000000000000093f6c": {CertKey: db.CertKey{SerialNumber: int60s.Bytes()}, RevocationTime: ts2},
-		"00000000000000000000000000000001e240": {CertKey: db.CertKey{SerialNumber: int123.Bytes()}, RevocationTime: ts2},
+		"00000000000000000000000000000000006f": {CertKey: db.CertKey{SerialNumber: int111.Bytes()}, RevocationTime: ts1, CRLDistributionPoint: dp},
+		"00000000000000000000000000000006c81c": {CertKey: db.CertKey{SerialNumber: int4s.Bytes()}, RevocationTime: ts1, CRLDistributionPoint: dp},
+		"000000000000000000000000000000093f6c": {CertKey: db.CertKey{SerialNumber: int60s.Bytes()}, RevocationTime: ts2, CRLDistributionPoint: dp},
+		"00000000000000000000000000000001e240": {CertKey: db.CertKey{SerialNumber: int123.Bytes()}, RevocationTime: ts2, CRLDistributionPoint: dp},
 	})
 
 	// Delete all the serials other than the 606060 serial
@@ -63,14 +79,119 @@ func smoketest(t *testing.T, handle *db.Database) {
 	require.NoError(t, handle.DeleteSerials(ctx, serials))
 
 	// The only remaining entry should be the serial 606060 one
-	remaining, err := handle.GetAllCerts(ctx)
+	remaining, err := handle.GetCertsForIDP(ctx, dp)
 	require.NoError(t, err)
 	expected := map[string]db.CertMetadata{
-		"000000000000000000000000000000093f6c": {CertKey: db.CertKey{SerialNumber: int60s.Bytes()}, RevocationTime: ts2},
+		"000000000000000000000000000000093f6c": {CertKey: db.CertKey{SerialNumber: int60s.Bytes()}, RevocationTime: ts2, CRLDistributionPoint: dp},
 	}
 	require.Equal(t, expected, remaining)
 }
 
+func TestQueriesWithMock(t *testing.T) {
+	querytest(t, mock.NewMockedDB(t))
+	querytest(t, mock.NewPaginatedMockedDB(t, 1))
+}
+
+// querytest exercises GetCertsForIDP and GetCertsRevokedBefore.
+// Like smoketest, it also runs against the downloadable DynamoDB in the
+// integration test, so it cleans up after itself and only looks at serials and
+// IDPs it created.
+func querytest(t *testing.T, handle *db.Database) {
+	ctx := context.Background()
+
+	// DynamoDB Local shares a table between tests, so keep the IDPs unique
+	idpA := fmt.Sprintf("http://a.example.com/%d.crl", time.Now().UnixNano())
+	idpB := fmt.Sprintf("http://b.example.com/%d.crl", time.Now().UnixNano())
+
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	recent := time.Now().Truncate(time.Second)
+
+	serialA1 := big.NewInt(70001)
+	serialA2 := big.NewInt(70002)
+	serialB := big.NewInt(70003)
+
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: serialA1, CRLDistributionPoints: []string{idpA}}, "", db.Revocation{Time: old}))
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: serialA2, CRLDistributionPoints: []string{idpA}}, "", db.Revocation{Time: recent}))
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: serialB, CRLDistributionPoints: []string{idpB}}, "", db.Revocation{Time: old}))
+
+	certsA, err := handle.GetCertsForIDP(ctx, idpA)
+	require.NoError(t, err)
+	require.Equal(t, map[string]db.CertMetadata{
+		db.NewCertKey(serialA1).SerialString(): {CertKey: db.NewCertKey(serialA1), RevocationTime: old, CRLDistributionPoint: idpA},
+		db.NewCertKey(serialA2).SerialString(): {CertKey: db.NewCertKey(serialA2), RevocationTime: recent, CRLDistributionPoint: idpA},
+	}, certsA)
+
+	certsB, err := handle.GetCertsForIDP(ctx, idpB)
+	require.NoError(t, err)
+	require.Equal(t, map[string]db.CertMetadata{
+		db.NewCertKey(serialB).SerialString(): {CertKey: db.NewCertKey(serialB), RevocationTime: old, CRLDistributionPoint: idpB},
+	}, certsB)
+
+	none, err := handle.GetCertsForIDP(ctx, "http://unknown.example.com/1.crl")
+	require.NoError(t, err)
+	require.Empty(t, none)
+
+	// Certificates revoked before the cutoff are found whatever their
+	// CRLDistributionPoint, but those revoked after it are not.
+	revoked, err := handle.GetCertsRevokedBefore(ctx, recent.Add(-time.Hour))
+	require.NoError(t, err)
+	for _, serial := range []*big.Int{serialA1, serialB} {
+		require.Contains(t, revoked, db.NewCertKey(serial).SerialString())
+	}
+	require.NotContains(t, revoked, db.NewCertKey(serialA2).SerialString())
+
+	require.NoError(t, handle.DeleteSerials(ctx, [][]byte{serialA1.Bytes(), serialA2.Bytes(), serialB.Bytes()}))
+}
+
+func TestBackfillPending(t *testing.T) {
+	for _, pageSize := range []int{0, 1} {
+		t.Run(fmt.Sprintf("page size %d", pageSize), func(t *testing.T) {
+			handle := mock.NewPaginatedMockedDB(t, pageSize)
+			ctx := context.Background()
+
+			old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+			legacy := big.NewInt(1)
+			current := big.NewInt(2)
+			noDP := big.NewInt(3)
+
+			// Rows stored before PT was written, one from before CRLDistributionPoints.
+			// That one is stored first, so with a page size of 1 the scan's first page
+			// filters down to nothing, and it has to carry on to find the other.
+			for _, cert := range []db.CertMetadata{
+				{CertKey: db.NewCertKey(noDP), RevocationTime: old},
+				{CertKey: db.NewCertKey(legacy), RevocationTime: old, CRLDistributionPoint: crlDP[0]},
+			} {
+				item, err := attributevalue.MarshalMap(cert)
+				require.NoError(t, err)
+				_, err = handle.Dynamo.PutItem(ctx, &dynamodb.PutItemInput{TableName: &handle.Table, Item: item})
+				require.NoError(t, err)
+			}
+			require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: current, CRLDistributionPoints: crlDP}, "", db.Revocation{Time: old}))
+
+			revoked, err := handle.GetCertsRevokedBefore(ctx, time.Now())
+			require.NoError(t, err)
+			require.Len(t, revoked, 1)
+			require.Contains(t, revoked, db.NewCertKey(current).SerialString())
+
+			updated, err := handle.BackfillPending(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 1, updated)
+
+			// The row without a CRLDistributionPoint is left out
+			revoked, err = handle.GetCertsRevokedBefore(ctx, time.Now())
+			require.NoError(t, err)
+			require.Len(t, revoked, 2)
+			require.Equal(t, old, revoked[db.NewCertKey(legacy).SerialString()].RevocationTime)
+			require.NotContains(t, revoked, db.NewCertKey(noDP).SerialString())
+
+			// Once every row has a PT, there's nothing left to do
+			updated, err = handle.BackfillPending(ctx)
+			require.NoError(t, err)
+			require.Zero(t, updated)
+		})
+	}
+}
+
 func TestAddCertCRLDP(t *testing.T) {
 	handle := mock.NewMockedDB(t)
 	ctx := context.Background()
@@ -83,9 +204,9 @@ func TestAddCertCRLDP(t *testing.T) {
 
 	err := handle.AddCert(ctx, &x509.Certificate{
 		SerialNumber: int111,
-	}, revocationTime)
-	if err != nil {
-		t.Errorf("inserting plain cert: %s", err)
+	}, "", db.Revocation{Time: revocationTime})
+	if err == nil {
+		t.Errorf("inserting plain cert: got success, want error")
 	}
 
 	err = handle.AddCert(ctx, &x509.Certificate{
@@ -94,7 +215,7 @@ func TestAddCertCRLDP(t *testing.T) {
 			"http://example.com/crl",
 			"http://example.net/crl",
 		},
-	}, revocationTime)
+	}, "", db.Revocation{Time: revocationTime})
 	if err == nil {
 		t.Errorf("inserting cert with two CRLDistributionPoints: got success, want error")
 	}
@@ -104,23 +225,188 @@ func TestAddCertCRLDP(t *testing.T) {
 		CRLDistributionPoints: []string{
 			"http://example.com/crl",
 		},
-	}, revocationTime)
+	}, "", db.Revocation{Time: revocationTime})
 	if err != nil {
 		t.Errorf("inserting cert with one CRLDistributionPoint: %s", err)
 	}
 
-	results, err := handle.GetAllCerts(ctx)
-	if err != nil {
-		t.Fatalf("getting all certs: %s", err)
-	}
+	results := mock.Certs(t, handle)
 
 	serialString := fmt.Sprintf("%036x", int60s)
 	metadata, ok := results[serialString]
 	if !ok {
-		t.Errorf("getting all certs: expected entry for %s, got %+v", serialString, metadata)
+		t.Errorf("expected entry for %s, got %+v", serialString, metadata)
 	}
 
 	if metadata.CRLDistributionPoint != "http://example.com/crl" {
 		t.Errorf("CRL for %s = %q, want %q", serialString, metadata.CRLDistributionPoint, "http://example.com/crl")
 	}
 }
+
+func TestDeleteSerialsBatching(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		throttles int
+	}{
+		{name: "unthrottled", throttles: 0},
+		{name: "throttled", throttles: 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			handle := mock.NewThrottledMockedDB(t, tt.throttles)
+			ctx := context.Background()
+
+			// More than two full batches of deletes, plus one to keep
+			var serials [][]byte
+			for i := range 60 {
+				serial := big.NewInt(int64(1000 + i))
+				require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: serial, CRLDistributionPoints: crlDP}, "", db.Revocation{Time: time.Now()}))
+				serials = append(serials, serial.Bytes())
+			}
+			require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: big.NewInt(1), CRLDistributionPoints: crlDP}, "", db.Revocation{Time: time.Now()}))
+
+			require.NoError(t, handle.DeleteSerials(ctx, serials))
+
+			remaining := mock.Certs(t, handle)
+			require.Len(t, remaining, 1)
+			require.Contains(t, remaining, db.NewCertKey(big.NewInt(1)).SerialString())
+		})
+	}
+}
+
+func TestAddCertNotBefore(t *testing.T) {
+	handle := mock.NewMockedDB(t)
+	ctx := context.Background()
+
+	notBefore := time.Now().Add(-time.Hour).Truncate(time.Second)
+	revocationTime := time.Now().Truncate(time.Second)
+
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: notBefore, CRLDistributionPoints: crlDP}, "", db.Revocation{Time: revocationTime}))
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: big.NewInt(2), CRLDistributionPoints: crlDP}, "", db.Revocation{Time: revocationTime}))
+
+	certs := mock.Certs(t, handle)
+	require.True(t, notBefore.Equal(certs[db.NewCertKey(big.NewInt(1)).SerialString()].NotBefore))
+	require.True(t, certs[db.NewCertKey(big.NewInt(2)).SerialString()].NotBefore.IsZero())
+}
+
+func TestAddCertRevocationAndVariant(t *testing.T) {
+	handle := mock.NewMockedDB(t)
+	ctx := context.Background()
+
+	revocationTime := time.Now().Truncate(time.Second)
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: big.NewInt(1), CRLDistributionPoints: crlDP}, "p384:sans=3", db.Revocation{
+		Time:       revocationTime,
+		Method:     db.RevokedByCertKey,
+		ReasonCode: 1,
+	}))
+	require.NoError(t, handle.AddCert(ctx, &x509.Certificate{SerialNumber: big.NewInt(2), CRLDistributionPoints: crlDP}, "", db.Revocation{Time: revocationTime}))
+
+	certs := mock.Certs(t, handle)
+	byKey := certs[db.NewCertKey(big.NewInt(1)).SerialString()]
+	require.Equal(t, db.RevokedByCertKey, byKey.RevocationMethod)
+	require.Equal(t, 1, byKey.ReasonCode)
+	require.Equal(t, "p384:sans=3", byKey.Variant)
+	legacy := certs[db.NewCertKey(big.NewInt(2)).SerialString()]
+	require.Empty(t, legacy.RevocationMethod)
+	require.Zero(t, legacy.ReasonCode)
+	require.Empty(t, legacy.Variant)
+}
+
+func TestPublicationLatencyWithMock(t *testing.T) {
+	latencytest(t, mock.NewMockedDB(t))
+}
+
+// latencytest records and summarizes publication latencies, in a fresh
+// LatencyTable.
+func latencytest(t *testing.T, handle *db.Database) {
+	ctx := context.Background()
+	day := time.Date(2026, 6, 1, 13, 0, 0, 0, time.UTC)
+
+	for _, latency := range []struct{ thisUpdate, uploaded time.Duration }{
+		{4 * time.Minute, 10 * time.Minute},
+		{5 * time.Minute, 11 * time.Minute},
+		{50 * time.Minute, time.Hour},
+		{72 * time.Hour, 73 * time.Hour},
+	} {
+		require.NoError(t, handle.RecordPublicationLatency(ctx, day, latency.thisUpdate, latency.uploaded))
+	}
+	// A different day has its own summary
+	require.NoError(t, handle.RecordPublicationLatency(ctx, day.Add(24*time.Hour), time.Minute, time.Minute))
+
+	summary, err := handle.GetLatencySummary(ctx, day.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), summary.Day)
+	require.Equal(t, []int64{2, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1}, summary.ThisUpdate)
+	require.Equal(t, []int64{0, 1, 1, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1}, summary.Uploaded)
+
+	require.Equal(t, 5*time.Minute, db.Percentile(summary.ThisUpdate, 50))
+	require.Equal(t, time.Hour, db.Percentile(summary.ThisUpdate, 75))
+	require.Equal(t, time.Duration(-1), db.Percentile(summary.ThisUpdate, 99))
+	require.Equal(t, 15*time.Minute, db.Percentile(summary.Uploaded, 50))
+
+	empty, err := handle.GetLatencySummary(ctx, day.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), db.Percentile(empty.ThisUpdate, 50))
+
+	// Without a latency table, nothing is recorded
+	handle.LatencyTable = ""
+	require.NoError(t, handle.RecordPublicationLatency(ctx, day, time.Minute, time.Minute))
+	_, err = handle.GetLatencySummary(ctx, day)
+	require.Error(t, err)
+}
+
+func TestAccountWithMock(t *testing.T) {
+	accounttest(t, mock.NewMockedDB(t))
+}
+
+// accounttest stores and loads ACME accounts, in a fresh AccountTable.
+func accounttest(t *testing.T, handle *db.Database) {
+	ctx := context.Background()
+	directory := "https://acme.example/directory"
+
+	_, err := handle.GetAccount(ctx, directory)
+	require.ErrorIs(t, err, db.ErrNoAccount)
+
+	account := &db.Account{Directory: directory, Location: "https://acme.example/acct/1", Key: []byte{1, 2, 3}}
+	require.NoError(t, handle.PutAccount(ctx, account))
+	loaded, err := handle.GetAccount(ctx, directory)
+	require.NoError(t, err)
+	require.Equal(t, account, loaded)
+
+	// Storing a new account replaces the old one
+	replacement := &db.Account{Directory: directory, Location: "https://acme.example/acct/2", Key: []byte{4, 5, 6}}
+	require.NoError(t, handle.PutAccount(ctx, replacement))
+	loaded, err = handle.GetAccount(ctx, directory)
+	require.NoError(t, err)
+	require.Equal(t, replacement, loaded)
+
+	// Each directory has its own account
+	_, err = handle.GetAccount(ctx, "https://other.example/directory")
+	require.ErrorIs(t, err, db.ErrNoAccount)
+}
+
+func TestExpiryWithMock(t *testing.T) {
+	expirytest(t, mock.NewMockedDB(t))
+}
+
+// expirytest stores and loads certificate expiry, in a fresh ExpiryTable.
+func expirytest(t *testing.T, handle *db.Database) {
+	ctx := context.Background()
+	serial := big.NewInt(0x2a0b)
+
+	_, err := handle.GetExpiry(ctx, serial)
+	require.ErrorIs(t, err, db.ErrNoExpiry)
+
+	notAfter := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, handle.PutExpiry(ctx, serial, notAfter))
+	loaded, err := handle.GetExpiry(ctx, serial)
+	require.NoError(t, err)
+	require.True(t, notAfter.Equal(loaded), "got %s", loaded)
+
+	// Each serial has its own entry
+	_, err = handle.GetExpiry(ctx, big.NewInt(0x2a0c))
+	require.ErrorIs(t, err, db.ErrNoExpiry)
+
+	// Without an expiry table, nothing is stored
+	handle.ExpiryTable = ""
+	require.Error(t, handle.PutExpiry(ctx, serial, notAfter))
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// ErrNoExpiry is returned by GetExpiry when no NotAfter is stored for a serial.
+var ErrNoExpiry = errors.New("no certificate expiry stored")
+
+// expiryRetention is how long after a certificate expires its entry in the
+// ExpiryTable is kept, which covers the delay before a CA removes it from a
+// CRL, when the checker looks it up.
+const expiryRetention = 7 * 24 * time.Hour
+
+// Expiry is the NotAfter of a certificate, stored so the checker doesn't need
+// to look it up again.
+type Expiry struct {
+	CertKey
+	NotAfter time.Time `dynamodbav:"NA,unixtime"`
+	// TTL is when DynamoDB may delete the entry, if Time to Live is enabled
+	// on the ExpiryTable with this attribute.
+	TTL time.Time `dynamodbav:"TTL,unixtime"`
+}
+
+// GetExpiry returns the NotAfter stored for a serial, or ErrNoExpiry if there
+// isn't one.
+func (db *Database) GetExpiry(ctx context.Context, serial *big.Int) (time.Time, error) {
+	if db.ExpiryTable == "" {
+		return time.Time{}, fmt.Errorf("no expiry table configured")
+	}
+
+	key, err := attributevalue.MarshalMap(NewCertKey(serial))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	resp, err := db.Dynamo.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.ExpiryTable),
+		Key:       key,
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("getting expiry for serial %x: %w", serial, err)
+	}
+	if resp.Item == nil {
+		return time.Time{}, ErrNoExpiry
+	}
+
+	var expiry Expiry
+	err = attributevalue.UnmarshalMap(resp.Item, &expiry)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unmarshalling expiry for serial %x: %w", serial, err)
+	}
+	return expiry.NotAfter, nil
+}
+
+// PutExpiry stores the NotAfter of a serial. It never changes, so any
+// existing entry is simply replaced.
+func (db *Database) PutExpiry(ctx context.Context, serial *big.Int, notAfter time.Time) error {
+	if db.ExpiryTable == "" {
+		return fmt.Errorf("no expiry table configured")
+	}
+
+	item, err := attributevalue.MarshalMap(Expiry{
+		CertKey:  NewCertKey(serial),
+		NotAfter: notAfter,
+		TTL:      notAfter.Add(expiryRetention),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Dynamo.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(db.ExpiryTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("putting expiry for serial %x: %w", serial, err)
+	}
+	return nil
+}
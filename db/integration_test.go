@@ -26,4 +26,14 @@ func TestIntegrationDynamoDB(t *testing.T) {
 	require.NoError(t, err)
 
 	smoketest(t, handle)
+	querytest(t, handle)
+
+	handle.LatencyTable = "publication-latency"
+	latencytest(t, handle)
+
+	handle.AccountTable = "churner-accounts"
+	accounttest(t, handle)
+
+	handle.ExpiryTable = "certificate-expiry"
+	expirytest(t, handle)
 }
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// LatencyBuckets are the upper bounds of the histogram buckets publication
+// latencies are counted in. Latencies above the last bound are counted in an
+// overflow bucket.
+var LatencyBuckets = []time.Duration{
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	3 * time.Hour,
+	4 * time.Hour,
+	6 * time.Hour,
+	8 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+	48 * time.Hour,
+}
+
+// latencyRetention is how long each day's summary is kept, using the
+// table's time to live on the TTL attribute.
+const latencyRetention = 90 * 24 * time.Hour
+
+// LatencySummary is a day's histograms of how long after a churned
+// certificate's revocation it appeared on a CRL. The counts are indexed like
+// LatencyBuckets, with one more for the overflow bucket.
+type LatencySummary struct {
+	Day time.Time
+	// ThisUpdate counts the time from revocation to the CRL's thisUpdate.
+	ThisUpdate []int64
+	// Uploaded counts the time from revocation to the CRL's upload to S3.
+	Uploaded []int64
+}
+
+// latencyDay is the primary key of the latency table for the day containing t.
+func latencyDay(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// latencyAttribute is the name of the attribute counting latencies in the
+// bucket containing latency, such as TU300 for up to five minutes, or TUInf.
+func latencyAttribute(prefix string, latency time.Duration) string {
+	for _, bound := range LatencyBuckets {
+		if latency <= bound {
+			return fmt.Sprintf("%s%d", prefix, int64(bound.Seconds()))
+		}
+	}
+	return prefix + "Inf"
+}
+
+// RecordPublicationLatency adds a seen certificate's publication latencies to
+// the summary for day in the LatencyTable. It does nothing if there's no
+// LatencyTable.
+func (db *Database) RecordPublicationLatency(ctx context.Context, day time.Time, thisUpdate, uploaded time.Duration) error {
+	if db.LatencyTable == "" {
+		return nil
+	}
+
+	_, err := db.Dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &db.LatencyTable,
+		Key: map[string]types.AttributeValue{
+			"Day": &types.AttributeValueMemberS{Value: latencyDay(day)},
+		},
+		UpdateExpression: aws.String("SET #ttl = :ttl ADD #tu :one, #ul :one"),
+		ExpressionAttributeNames: map[string]string{
+			"#ttl": "TTL",
+			"#tu":  latencyAttribute("TU", thisUpdate),
+			"#ul":  latencyAttribute("UL", uploaded),
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ttl": &types.AttributeValueMemberN{Value: strconv.FormatInt(day.Add(latencyRetention).Unix(), 10)},
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("recording publication latency: %w", err)
+	}
+	return nil
+}
+
+// GetLatencySummary returns the publication latency summary for the day
+// containing day. Days without any latencies recorded have all zero counts.
+func (db *Database) GetLatencySummary(ctx context.Context, day time.Time) (*LatencySummary, error) {
+	if db.LatencyTable == "" {
+		return nil, fmt.Errorf("no latency table configured")
+	}
+
+	resp, err := db.Dynamo.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &db.LatencyTable,
+		Key: map[string]types.AttributeValue{
+			"Day": &types.AttributeValueMemberS{Value: latencyDay(day)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting latency summary: %w", err)
+	}
+
+	summary := &LatencySummary{
+		Day:        day.UTC().Truncate(24 * time.Hour),
+		ThisUpdate: make([]int64, len(LatencyBuckets)+1),
+		Uploaded:   make([]int64, len(LatencyBuckets)+1),
+	}
+	for prefix, counts := range map[string][]int64{"TU": summary.ThisUpdate, "UL": summary.Uploaded} {
+		for i := range counts {
+			name := prefix + "Inf"
+			if i < len(LatencyBuckets) {
+				name = latencyAttribute(prefix, LatencyBuckets[i])
+			}
+			value, ok := resp.Item[name].(*types.AttributeValueMemberN)
+			if !ok {
+				continue
+			}
+			counts[i], err = strconv.ParseInt(value.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing latency count %s: %w", name, err)
+			}
+		}
+	}
+	return summary, nil
+}
+
+// Percentile returns the upper bound of the bucket containing the p'th
+// percentile (0 < p <= 100) of counts, which is one of the histograms in a
+// LatencySummary. It returns 0 if counts is empty, and -1 if the percentile
+// is in the overflow bucket.
+func Percentile(counts []int64, p float64) time.Duration {
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := p / 100 * float64(total)
+	var cumulative int64
+	for i, count := range counts {
+		cumulative += count
+		if float64(cumulative) >= rank {
+			if i < len(LatencyBuckets) {
+				return LatencyBuckets[i]
+			}
+			break
+		}
+	}
+	return -1
+}
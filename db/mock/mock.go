@@ -3,8 +3,14 @@ package mock
 import (
 	"bytes"
 	"context"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/stretchr/testify/require"
@@ -12,21 +18,80 @@ import (
 	"github.com/letsencrypt/crl-monitor/db"
 )
 
-const Table = "table"
+const (
+	Table        = "table"
+	LatencyTable = "latency"
+	AccountTable = "accounts"
+	ExpiryTable  = "expiry"
+)
 
 // NewMockedDB returns an in-memory Database using a mocked DynamoDB
 // It is meant only for use in tests.
 func NewMockedDB(t *testing.T) *db.Database {
+	return NewPaginatedMockedDB(t, 0)
+}
+
+// NewPaginatedMockedDB is like NewMockedDB, but Scan and Query return at most
+// pageSize items per call along with a LastEvaluatedKey, like DynamoDB does when
+// a response exceeds 1MB. A pageSize of 0 returns all items in a single page.
+func NewPaginatedMockedDB(t *testing.T, pageSize int) *db.Database {
 	return &db.Database{
-		Table:  Table,
-		Dynamo: &dynamoMock{t: t},
+		Table:        Table,
+		LatencyTable: LatencyTable,
+		AccountTable: AccountTable,
+		ExpiryTable:  ExpiryTable,
+		Dynamo:       &dynamoMock{t: t, pageSize: pageSize},
 	}
 }
 
+// NewThrottledMockedDB is like NewMockedDB, but the first `throttles` calls to
+// BatchWriteItem only process the first half of their batch and return the
+// rest as UnprocessedItems, like DynamoDB does when throughput is exceeded.
+func NewThrottledMockedDB(t *testing.T, throttles int) *db.Database {
+	return &db.Database{
+		Table:        Table,
+		LatencyTable: LatencyTable,
+		AccountTable: AccountTable,
+		ExpiryTable:  ExpiryTable,
+		Dynamo:       &dynamoMock{t: t, throttles: throttles},
+	}
+}
+
+// Certs returns every certificate in the Table of a Database from NewMockedDB,
+// keyed by CertKey.SerialString, including any without the attributes the
+// indexes are keyed on.
+func Certs(t *testing.T, database *db.Database) map[string]db.CertMetadata {
+	d, ok := database.Dynamo.(*dynamoMock)
+	require.True(t, ok, "Database is not mocked")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	certs := make(map[string]db.CertMetadata)
+	for _, item := range d.data {
+		var cm db.CertMetadata
+		require.NoError(t, attributevalue.UnmarshalMap(item, &cm))
+		certs[cm.SerialString()] = cm
+	}
+	return certs
+}
+
 type dynamoMock struct {
-	t *testing.T
+	t         *testing.T
+	pageSize  int
+	throttles int
+
+	// mu guards everything below, as callers such as expiry.CachingFetcher
+	// use the database from several goroutines
+	mu sync.Mutex
 
 	data []map[string]types.AttributeValue
+	// latency holds the LatencyTable, keyed on the Day
+	latency map[string]map[string]types.AttributeValue
+	// accounts holds the AccountTable, keyed on the Directory
+	accounts map[string]map[string]types.AttributeValue
+	// expiry holds the ExpiryTable, keyed on the serial bytes
+	expiry map[string]map[string]types.AttributeValue
 }
 
 func has(key map[string]types.AttributeValue, item map[string]types.AttributeValue) bool {
@@ -39,10 +104,22 @@ func has(key map[string]types.AttributeValue, item map[string]types.AttributeVal
 }
 
 func (d *dynamoMock) BatchWriteItem(ctx context.Context, input *dynamodb.BatchWriteItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	require.Empty(d.t, opts, "Options not supported")
 	require.NotNil(d.t, input)
 
-	for _, item := range input.RequestItems[Table] {
+	requests := input.RequestItems[Table]
+	require.LessOrEqual(d.t, len(requests), 25, "DynamoDB rejects batches of more than 25 items")
+
+	var unprocessed []types.WriteRequest
+	if d.throttles > 0 {
+		d.throttles--
+		requests, unprocessed = requests[:len(requests)/2], requests[len(requests)/2:]
+	}
+
+	for _, item := range requests {
 		require.Nil(d.t, item.PutRequest, "Only delete requests supported")
 		require.NotNil(d.t, item.DeleteRequest)
 
@@ -56,18 +133,239 @@ func (d *dynamoMock) BatchWriteItem(ctx context.Context, input *dynamodb.BatchWr
 		}
 		d.data = filtered
 	}
-	return &dynamodb.BatchWriteItemOutput{}, nil
+
+	resp := &dynamodb.BatchWriteItemOutput{}
+	if len(unprocessed) > 0 {
+		resp.UnprocessedItems = map[string][]types.WriteRequest{Table: unprocessed}
+	}
+	return resp, nil
 }
 
 func (d *dynamoMock) PutItem(ctx context.Context, input *dynamodb.PutItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	require.Empty(d.t, opts, "Options not supported")
 	require.NotNil(d.t, input)
+	if *input.TableName == AccountTable {
+		if d.accounts == nil {
+			d.accounts = make(map[string]map[string]types.AttributeValue)
+		}
+		d.accounts[input.Item["Directory"].(*types.AttributeValueMemberS).Value] = input.Item
+		return &dynamodb.PutItemOutput{}, nil
+	}
+	if *input.TableName == ExpiryTable {
+		if d.expiry == nil {
+			d.expiry = make(map[string]map[string]types.AttributeValue)
+		}
+		d.expiry[string(input.Item["SN"].(*types.AttributeValueMemberB).Value)] = input.Item
+		return &dynamodb.PutItemOutput{}, nil
+	}
 	d.data = append(d.data, input.Item)
 	return &dynamodb.PutItemOutput{}, nil
 }
 
+func (d *dynamoMock) GetItem(ctx context.Context, input *dynamodb.GetItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	require.Empty(d.t, opts, "Options not supported")
+	require.NotNil(d.t, input)
+
+	switch *input.TableName {
+	case LatencyTable:
+		day := input.Key["Day"].(*types.AttributeValueMemberS).Value
+		// UpdateItem changes the item in place, so return a copy
+		return &dynamodb.GetItemOutput{Item: maps.Clone(d.latency[day])}, nil
+	case AccountTable:
+		directory := input.Key["Directory"].(*types.AttributeValueMemberS).Value
+		return &dynamodb.GetItemOutput{Item: d.accounts[directory]}, nil
+	case ExpiryTable:
+		serial := input.Key["SN"].(*types.AttributeValueMemberB).Value
+		return &dynamodb.GetItemOutput{Item: d.expiry[string(serial)]}, nil
+	}
+	require.Fail(d.t, "Only the latency, account and expiry tables are supported")
+	return nil, nil
+}
+
+// UpdateItem supports SET and ADD clauses on the latency table, and SET
+// clauses on existing items of the certificate table, which is all
+// db.Database uses.
+func (d *dynamoMock) UpdateItem(ctx context.Context, input *dynamodb.UpdateItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	require.Empty(d.t, opts, "Options not supported")
+	require.NotNil(d.t, input)
+	require.NotNil(d.t, input.UpdateExpression)
+
+	var item map[string]types.AttributeValue
+	switch *input.TableName {
+	case LatencyTable:
+		day := input.Key["Day"].(*types.AttributeValueMemberS).Value
+		if d.latency == nil {
+			d.latency = make(map[string]map[string]types.AttributeValue)
+		}
+		var ok bool
+		item, ok = d.latency[day]
+		if !ok {
+			item = map[string]types.AttributeValue{"Day": input.Key["Day"]}
+			d.latency[day] = item
+		}
+	case Table:
+		require.Equal(d.t, "attribute_exists(SN)", aws.ToString(input.ConditionExpression), "Only updates of existing certificates are supported")
+		for _, i := range d.data {
+			if has(input.Key, i) {
+				item = i
+			}
+		}
+		if item == nil {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	default:
+		require.Fail(d.t, "Only the latency and certificate tables are supported")
+	}
+
+	name := func(n string) string {
+		if resolved, ok := input.ExpressionAttributeNames[n]; ok {
+			return resolved
+		}
+		return n
+	}
+
+	apply := func(clause string, action []string) {
+		switch {
+		case clause == "SET" && len(action) == 3 && action[1] == "=":
+			item[name(action[0])] = input.ExpressionAttributeValues[action[2]]
+		case clause == "ADD" && len(action) == 2:
+			var current int64
+			if existing, ok := item[name(action[0])]; ok {
+				current = d.number(existing)
+			}
+			sum := current + d.number(input.ExpressionAttributeValues[action[1]])
+			item[name(action[0])] = &types.AttributeValueMemberN{Value: strconv.FormatInt(sum, 10)}
+		default:
+			require.Failf(d.t, "unsupported update expression", "%q", *input.UpdateExpression)
+		}
+	}
+
+	// Tokenize "SET a = :x ADD b :y, c :z" into clauses of comma-separated actions
+	var clause string
+	var action []string
+	for _, token := range strings.Fields(strings.ReplaceAll(*input.UpdateExpression, ",", " , ")) {
+		switch token {
+		case "SET", "ADD":
+			if action != nil {
+				apply(clause, action)
+			}
+			clause, action = token, nil
+		case ",":
+			apply(clause, action)
+			action = nil
+		default:
+			action = append(action, token)
+		}
+	}
+	apply(clause, action)
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (d *dynamoMock) Query(ctx context.Context, input *dynamodb.QueryInput, opts ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	require.Empty(d.t, opts, "Options not supported")
+	require.NotNil(d.t, input)
+	require.NotNil(d.t, input.IndexName)
+	require.Contains(d.t, []string{db.IDPIndex, db.RevocationTimeIndex}, *input.IndexName, "Only the IDP and revocation time indexes are supported")
+	require.NotNil(d.t, input.KeyConditionExpression)
+
+	matching := d.filter(*input.KeyConditionExpression, input.ExpressionAttributeValues, d.data)
+	items, lastEvaluatedKey := d.page(matching, input.ExclusiveStartKey)
+	return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: lastEvaluatedKey}, nil
+}
+
 func (d *dynamoMock) Scan(ctx context.Context, input *dynamodb.ScanInput, opts ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	require.Empty(d.t, opts, "Options not supported")
 	require.NotNil(d.t, input)
-	return &dynamodb.ScanOutput{Items: d.data}, nil
+
+	// Like DynamoDB, page through the table before applying any filter
+	items, lastEvaluatedKey := d.page(d.data, input.ExclusiveStartKey)
+	if input.FilterExpression != nil {
+		items = d.filter(*input.FilterExpression, input.ExpressionAttributeValues, items)
+	}
+	return &dynamodb.ScanOutput{Items: items, LastEvaluatedKey: lastEvaluatedKey}, nil
+}
+
+// page returns up to pageSize items following the startKey, and the key to
+// resume from if there are more.
+func (d *dynamoMock) page(items []map[string]types.AttributeValue, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue) {
+	// Resume after the ExclusiveStartKey if this is a follow-up page
+	start := 0
+	if startKey != nil {
+		start = -1
+		for i, item := range items {
+			if has(startKey, item) {
+				start = i + 1
+				break
+			}
+		}
+		require.NotEqual(d.t, -1, start, "ExclusiveStartKey not found")
+	}
+
+	end := len(items)
+	if d.pageSize > 0 && start+d.pageSize < end {
+		end = start + d.pageSize
+	}
+
+	var lastEvaluatedKey map[string]types.AttributeValue
+	if end < len(items) {
+		lastEvaluatedKey = map[string]types.AttributeValue{"SN": items[end-1]["SN"]}
+	}
+	return items[start:end], lastEvaluatedKey
+}
+
+// filter evaluates the handful of expressions db.Database uses against items.
+func (d *dynamoMock) filter(expression string, values map[string]types.AttributeValue, items []map[string]types.AttributeValue) []map[string]types.AttributeValue {
+	var matches func(item map[string]types.AttributeValue) bool
+	switch expression {
+	case "DP = :dp":
+		dp := values[":dp"].(*types.AttributeValueMemberS).Value
+		matches = func(item map[string]types.AttributeValue) bool {
+			itemDP, ok := item["DP"].(*types.AttributeValueMemberS)
+			return ok && itemDP.Value == dp
+		}
+	case "attribute_not_exists(PT) AND attribute_exists(DP)":
+		matches = func(item map[string]types.AttributeValue) bool {
+			_, hasPT := item["PT"]
+			_, hasDP := item["DP"]
+			return !hasPT && hasDP
+		}
+	case "PT = :pt AND RT < :cutoff":
+		pt := values[":pt"].(*types.AttributeValueMemberS).Value
+		cutoff := d.number(values[":cutoff"])
+		matches = func(item map[string]types.AttributeValue) bool {
+			itemPT, ok := item["PT"].(*types.AttributeValueMemberS)
+			return ok && itemPT.Value == pt && d.number(item["RT"]) < cutoff
+		}
+	default:
+		require.Failf(d.t, "unsupported expression", "%q", expression)
+	}
+
+	var filtered []map[string]types.AttributeValue
+	for _, item := range items {
+		if matches(item) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+func (d *dynamoMock) number(value types.AttributeValue) int64 {
+	n, err := strconv.ParseInt(value.(*types.AttributeValueMemberN).Value, 10, 64)
+	require.NoError(d.t, err)
+	return n
 }
@@ -0,0 +1,513 @@
+package e2e
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/mholt/acmez/v3/acme"
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/checker/crltest"
+	storagemock "github.com/letsencrypt/crl-monitor/storage/mock"
+)
+
+// fakeCA implements enough of ACME for the churner to register, order a
+// certificate validated with dns-01, and revoke it, along with a single CRL
+// shard which it serves over HTTP and uploads to a bucket. DNS-01 records are
+// looked up with a libdns provider, rather than in real DNS, and signatures
+// aren't checked.
+//
+// Like Boulder, revocation requests signed with the certificate's key are
+// always published with reason keyCompromise.
+type fakeCA struct {
+	*httptest.Server
+	t *testing.T
+
+	issuer    *x509.Certificate
+	issuerKey crypto.Signer
+	dns       libdns.RecordGetter
+	zone      string
+	bucket    *storagemock.Bucket
+
+	mu sync.Mutex
+	// thumbprints maps each account's URL to its JWK thumbprint
+	thumbprints map[string]string
+	accounts    map[string]string // JWK thumbprint to account URL
+	orders      []*fakeOrder
+	authzs      []*fakeAuthz
+	certs       []*x509.Certificate
+	revoked     []x509.RevocationListEntry
+	crlNumber   int64
+	thisUpdate  time.Time
+	crl         []byte
+}
+
+type fakeOrder struct {
+	account string
+	order   acme.Order
+	authzs  []*fakeAuthz
+	cert    int
+}
+
+type fakeAuthz struct {
+	account string
+	authz   acme.Authorization
+}
+
+// newFakeCA starts a fakeCA which validates names in zone with records from
+// dns, and uploads its CRL to bucket. The initial, empty, CRL is uploaded
+// before it returns.
+func newFakeCA(t *testing.T, dns libdns.RecordGetter, zone string, bucket *storagemock.Bucket) *fakeCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "fake CA intermediate"},
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	issuer, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &fakeCA{
+		t:           t,
+		issuer:      issuer,
+		issuerKey:   key,
+		dns:         dns,
+		zone:        zone,
+		bucket:      bucket,
+		thumbprints: make(map[string]string),
+		accounts:    make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /directory", ca.directory)
+	mux.HandleFunc("HEAD /nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+	})
+	mux.HandleFunc("POST /new-account", ca.newAccount)
+	mux.HandleFunc("POST /new-order", ca.newOrder)
+	mux.HandleFunc("POST /order/{id}", ca.getOrder)
+	mux.HandleFunc("POST /authz/{id}", ca.getAuthz)
+	mux.HandleFunc("POST /chall/{id}", ca.validate)
+	mux.HandleFunc("POST /finalize/{id}", ca.finalize)
+	mux.HandleFunc("POST /cert/{id}", ca.getCert)
+	mux.HandleFunc("POST /revoke-cert", ca.revoke)
+	mux.HandleFunc("GET /crl/0.crl", ca.getCRL)
+	ca.Server = httptest.NewServer(mux)
+	t.Cleanup(ca.Close)
+
+	ca.PublishCRL()
+	return ca
+}
+
+// Directory is the URL of the ACME directory.
+func (ca *fakeCA) Directory() string {
+	return ca.URL + "/directory"
+}
+
+// CRLURL is both the CRLDistributionPoint of issued certificates and the
+// IssuingDistributionPoint of the CRL.
+func (ca *fakeCA) CRLURL() string {
+	return ca.URL + "/crl/0.crl"
+}
+
+// Object is the name the CRL is uploaded as: the issuer's name ID and the
+// shard number, as Boulder's crl-storer does.
+func (ca *fakeCA) Object() string {
+	h := sha1.Sum(ca.issuer.RawSubject)
+	return fmt.Sprintf("%d/0.crl", big.NewInt(0).SetBytes(h[:7]))
+}
+
+// Issued returns every certificate the CA has issued.
+func (ca *fakeCA) Issued() []*x509.Certificate {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	return append([]*x509.Certificate(nil), ca.certs...)
+}
+
+// PublishCRL signs a new version of the CRL, with every certificate revoked
+// so far, serves it, and uploads it to the bucket.
+func (ca *fakeCA) PublishCRL() {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	// CRL times only have second precision, and each version's thisUpdate
+	// mustn't be before the last
+	thisUpdate := time.Now().Truncate(time.Second)
+	if !thisUpdate.After(ca.thisUpdate) {
+		thisUpdate = ca.thisUpdate.Add(time.Second)
+	}
+	ca.thisUpdate = thisUpdate
+	ca.crlNumber++
+
+	crl := crltest.MakeCRL(ca.t, &x509.RevocationList{
+		Number:                    big.NewInt(ca.crlNumber),
+		ThisUpdate:                thisUpdate,
+		NextUpdate:                thisUpdate.Add(24 * time.Hour),
+		RevokedCertificateEntries: ca.revoked,
+	}, ca.CRLURL(), ca.issuer, ca.issuerKey)
+
+	ca.crl = crl
+	ca.bucket.Put(ca.Object(), crl)
+}
+
+func (ca *fakeCA) getCRL(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	_, _ = w.Write(ca.crl)
+}
+
+func (ca *fakeCA) directory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(ca.t, json.NewEncoder(w).Encode(map[string]string{
+		"newNonce":   ca.URL + "/nonce",
+		"newAccount": ca.URL + "/new-account",
+		"newOrder":   ca.URL + "/new-order",
+		"revokeCert": ca.URL + "/revoke-cert",
+	}))
+}
+
+// jws is a flattened JWS, with its protected header decoded
+type jws struct {
+	JWK     json.RawMessage
+	KID     string
+	Payload []byte
+}
+
+func (ca *fakeCA) parseJWS(r *http.Request) jws {
+	var body struct{ Protected, Payload string }
+	require.NoError(ca.t, json.NewDecoder(r.Body).Decode(&body))
+
+	var request jws
+	protected, err := base64.RawURLEncoding.DecodeString(body.Protected)
+	require.NoError(ca.t, err)
+	require.NoError(ca.t, json.Unmarshal(protected, &request))
+	request.Payload, err = base64.RawURLEncoding.DecodeString(body.Payload)
+	require.NoError(ca.t, err)
+	return request
+}
+
+// thumbprint computes the RFC 7638 thumbprint of a JWK. Marshalling a map
+// sorts its keys, which gives the required canonical form.
+func (ca *fakeCA) thumbprint(jwk json.RawMessage) string {
+	var fields map[string]string
+	require.NoError(ca.t, json.Unmarshal(jwk, &fields))
+
+	required := map[string][]string{"EC": {"crv", "kty", "x", "y"}, "RSA": {"e", "kty", "n"}}[fields["kty"]]
+	require.NotEmpty(ca.t, required, "unsupported key type %q", fields["kty"])
+	canonical := make(map[string]string)
+	for _, name := range required {
+		canonical[name] = fields[name]
+	}
+	encoded, err := json.Marshal(canonical)
+	require.NoError(ca.t, err)
+	digest := sha256.Sum256(encoded)
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+func (ca *fakeCA) reply(w http.ResponseWriter, status int, location string, body any) {
+	w.Header().Set("Replay-Nonce", "nonce")
+	w.Header().Set("Content-Type", "application/json")
+	if location != "" {
+		w.Header().Set("Location", location)
+	}
+	w.WriteHeader(status)
+	require.NoError(ca.t, json.NewEncoder(w).Encode(body))
+}
+
+func (ca *fakeCA) problem(w http.ResponseWriter, status int, problemType, detail string) {
+	w.Header().Set("Replay-Nonce", "nonce")
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	require.NoError(ca.t, json.NewEncoder(w).Encode(map[string]string{"type": problemType, "detail": detail}))
+}
+
+func (ca *fakeCA) newAccount(w http.ResponseWriter, r *http.Request) {
+	request := ca.parseJWS(r)
+	var payload struct{ OnlyReturnExisting bool }
+	require.NoError(ca.t, json.Unmarshal(request.Payload, &payload))
+	thumbprint := ca.thumbprint(request.JWK)
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	location, ok := ca.accounts[thumbprint]
+	if !ok {
+		if payload.OnlyReturnExisting {
+			ca.problem(w, http.StatusBadRequest, acme.ProblemTypeAccountDoesNotExist, "no account for key")
+			return
+		}
+		location = fmt.Sprintf("%s/account/%d", ca.URL, len(ca.accounts))
+		ca.accounts[thumbprint] = location
+		ca.thumbprints[location] = thumbprint
+	}
+	ca.reply(w, http.StatusCreated, location, map[string]string{"status": acme.StatusValid})
+}
+
+func (ca *fakeCA) newOrder(w http.ResponseWriter, r *http.Request) {
+	request := ca.parseJWS(r)
+	var payload struct{ Identifiers []acme.Identifier }
+	require.NoError(ca.t, json.Unmarshal(request.Payload, &payload))
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if _, ok := ca.thumbprints[request.KID]; !ok {
+		ca.problem(w, http.StatusUnauthorized, acme.ProblemTypeAccountDoesNotExist, "unknown account")
+		return
+	}
+
+	id := len(ca.orders)
+	order := &fakeOrder{account: request.KID, cert: -1, order: acme.Order{
+		Status:      acme.StatusPending,
+		Identifiers: payload.Identifiers,
+		Finalize:    fmt.Sprintf("%s/finalize/%d", ca.URL, id),
+	}}
+	for _, identifier := range payload.Identifiers {
+		// Wildcard names are validated with a challenge for the base name
+		name, wildcard := strings.CutPrefix(identifier.Value, "*.")
+		authzID := len(ca.authzs)
+		authz := &fakeAuthz{account: request.KID, authz: acme.Authorization{
+			Status:     acme.StatusPending,
+			Identifier: acme.Identifier{Type: identifier.Type, Value: name},
+			Wildcard:   wildcard,
+			Challenges: []acme.Challenge{{
+				Type:   acme.ChallengeTypeDNS01,
+				URL:    fmt.Sprintf("%s/chall/%d", ca.URL, authzID),
+				Status: acme.StatusPending,
+				Token:  fmt.Sprintf("token%d", authzID),
+			}},
+		}}
+		ca.authzs = append(ca.authzs, authz)
+		order.authzs = append(order.authzs, authz)
+		order.order.Authorizations = append(order.order.Authorizations, fmt.Sprintf("%s/authz/%d", ca.URL, authzID))
+	}
+	ca.orders = append(ca.orders, order)
+	ca.reply(w, http.StatusCreated, fmt.Sprintf("%s/order/%d", ca.URL, id), order.order)
+}
+
+// lookup returns the item with the request's id, if it belongs to account
+func lookup[T any](ca *fakeCA, w http.ResponseWriter, r *http.Request, items []T, owner func(T) string, account string) (T, bool) {
+	var id int
+	_, err := fmt.Sscan(r.PathValue("id"), &id)
+	if err != nil || id < 0 || id >= len(items) || owner(items[id]) != account {
+		ca.problem(w, http.StatusNotFound, acme.ProblemTypeMalformed, "not found")
+		var zero T
+		return zero, false
+	}
+	return items[id], true
+}
+
+func orderOwner(o *fakeOrder) string { return o.account }
+func authzOwner(a *fakeAuthz) string { return a.account }
+
+func (ca *fakeCA) getOrder(w http.ResponseWriter, r *http.Request) {
+	request := ca.parseJWS(r)
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if order, ok := lookup(ca, w, r, ca.orders, orderOwner, request.KID); ok {
+		ca.reply(w, http.StatusOK, "", order.order)
+	}
+}
+
+func (ca *fakeCA) getAuthz(w http.ResponseWriter, r *http.Request) {
+	request := ca.parseJWS(r)
+	var payload struct{ Status string }
+	if len(request.Payload) > 0 {
+		require.NoError(ca.t, json.Unmarshal(request.Payload, &payload))
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	authz, ok := lookup(ca, w, r, ca.authzs, authzOwner, request.KID)
+	if !ok {
+		return
+	}
+	if payload.Status == acme.StatusDeactivated {
+		authz.authz.Status = acme.StatusDeactivated
+	}
+	ca.reply(w, http.StatusOK, "", authz.authz)
+}
+
+// validate checks the dns-01 challenge immediately, so the authorization is
+// already final when the client polls it.
+func (ca *fakeCA) validate(w http.ResponseWriter, r *http.Request) {
+	request := ca.parseJWS(r)
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	authz, ok := lookup(ca, w, r, ca.authzs, authzOwner, request.KID)
+	if !ok {
+		return
+	}
+
+	challenge := &authz.authz.Challenges[0]
+	if challenge.Status == acme.StatusPending {
+		challenge.Identifier = authz.authz.Identifier
+		challenge.KeyAuthorization = challenge.Token + "." + ca.thumbprints[request.KID]
+		records, err := ca.dns.GetRecords(r.Context(), ca.zone)
+		require.NoError(ca.t, err)
+
+		challenge.Status = acme.StatusInvalid
+		name := libdns.RelativeName(challenge.DNS01TXTRecordName(), ca.zone)
+		for _, record := range records {
+			rr := record.RR()
+			if rr.Type == "TXT" && rr.Name == name && rr.Data == challenge.DNS01KeyAuthorization() {
+				challenge.Status = acme.StatusValid
+			}
+		}
+		if challenge.Status == acme.StatusValid {
+			challenge.Validated = time.Now().Format(time.RFC3339)
+		} else {
+			challenge.Error = &acme.Problem{Type: acme.ProblemTypeUnauthorized, Detail: "no TXT record for " + name}
+		}
+		authz.authz.Status = challenge.Status
+	}
+
+	ca.reply(w, http.StatusOK, "", challenge)
+}
+
+func (ca *fakeCA) finalize(w http.ResponseWriter, r *http.Request) {
+	request := ca.parseJWS(r)
+	var payload struct{ CSR string }
+	require.NoError(ca.t, json.Unmarshal(request.Payload, &payload))
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	order, ok := lookup(ca, w, r, ca.orders, orderOwner, request.KID)
+	if !ok {
+		return
+	}
+	for _, authz := range order.authzs {
+		if authz.authz.Status != acme.StatusValid {
+			ca.problem(w, http.StatusForbidden, acme.ProblemTypeOrderNotReady, "authorizations aren't valid")
+			return
+		}
+	}
+
+	csrDER, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	require.NoError(ca.t, err)
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	require.NoError(ca.t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(0).Lsh(big.NewInt(1), 128))
+	require.NoError(ca.t, err)
+	now := time.Now()
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: serial,
+		// Backdated, as Boulder does
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		DNSNames:              csr.DNSNames,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		CRLDistributionPoints: []string{ca.CRLURL()},
+	}, ca.issuer, csr.PublicKey, ca.issuerKey)
+	require.NoError(ca.t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(ca.t, err)
+
+	order.cert = len(ca.certs)
+	ca.certs = append(ca.certs, cert)
+	order.order.Status = acme.StatusValid
+	order.order.Certificate = fmt.Sprintf("%s/cert/%d", ca.URL, order.cert)
+	ca.reply(w, http.StatusOK, "", order.order)
+}
+
+func (ca *fakeCA) getCert(w http.ResponseWriter, r *http.Request) {
+	request := ca.parseJWS(r)
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	order, ok := lookup(ca, w, r, ca.orders, orderOwner, request.KID)
+	if !ok {
+		return
+	}
+	if order.cert < 0 {
+		ca.problem(w, http.StatusNotFound, acme.ProblemTypeMalformed, "order isn't finalized")
+		return
+	}
+
+	w.Header().Set("Replay-Nonce", "nonce")
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	require.NoError(ca.t, pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.certs[order.cert].Raw}))
+	require.NoError(ca.t, pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.issuer.Raw}))
+}
+
+// revoke revokes a certificate. Requests signed by an account must be from
+// the account which ordered it, and request a reason subscribers may use.
+func (ca *fakeCA) revoke(w http.ResponseWriter, r *http.Request) {
+	request := ca.parseJWS(r)
+	var payload struct {
+		Certificate string
+		Reason      int
+	}
+	require.NoError(ca.t, json.Unmarshal(request.Payload, &payload))
+	der, err := base64.RawURLEncoding.DecodeString(payload.Certificate)
+	require.NoError(ca.t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(ca.t, err)
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	for _, entry := range ca.revoked {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			ca.problem(w, http.StatusBadRequest, acme.ProblemTypeAlreadyRevoked, "already revoked")
+			return
+		}
+	}
+
+	reason := acme.ReasonKeyCompromise
+	if request.JWK == nil {
+		owned := false
+		for _, order := range ca.orders {
+			if order.account == request.KID && order.cert >= 0 && ca.certs[order.cert].SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				owned = true
+			}
+		}
+		if !owned {
+			ca.problem(w, http.StatusForbidden, acme.ProblemTypeUnauthorized, "certificate wasn't ordered by this account")
+			return
+		}
+		switch payload.Reason {
+		case acme.ReasonUnspecified, acme.ReasonKeyCompromise, acme.ReasonSuperseded, acme.ReasonCessationOfOperation:
+			reason = payload.Reason
+		default:
+			ca.problem(w, http.StatusBadRequest, acme.ProblemTypeBadRevocationReason, "reason not allowed")
+			return
+		}
+	}
+
+	ca.revoked = append(ca.revoked, x509.RevocationListEntry{
+		SerialNumber:   cert.SerialNumber,
+		RevocationTime: time.Now(),
+		ReasonCode:     reason,
+	})
+	w.Header().Set("Replay-Nonce", "nonce")
+	w.WriteHeader(http.StatusOK)
+}
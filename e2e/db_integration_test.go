@@ -0,0 +1,45 @@
+//go:build integration
+
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/db"
+)
+
+// newDatabase uses DynamoDB Local, with the tables from db/create_table.sh.
+// The certificates the test adds are deleted when it finishes, so they don't
+// show up in other tests sharing the table.
+func newDatabase(t *testing.T) *db.Database {
+	t.Setenv("AWS_ACCESS_KEY_ID", "Bogus")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "Bogus")
+	t.Setenv("AWS_REGION", "us-west-2")
+
+	database, err := db.New(context.Background(), "unseen-certificates", "http://localhost:8000")
+	require.NoError(t, err)
+	database.LatencyTable = "publication-latency"
+
+	// Everything AddCert stores is pending, so it all shows up in this index
+	farFuture := time.Now().AddDate(100, 0, 0)
+	existing, err := database.GetCertsRevokedBefore(context.Background(), farFuture)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		certs, err := database.GetCertsRevokedBefore(ctx, farFuture)
+		require.NoError(t, err)
+
+		var added [][]byte
+		for serial, cert := range certs {
+			if _, ok := existing[serial]; !ok {
+				added = append(added, cert.SerialNumber)
+			}
+		}
+		require.NoError(t, database.DeleteSerials(ctx, added))
+	})
+	return database
+}
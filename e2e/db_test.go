@@ -0,0 +1,14 @@
+//go:build !integration
+
+package e2e
+
+import (
+	"testing"
+
+	"github.com/letsencrypt/crl-monitor/db"
+	"github.com/letsencrypt/crl-monitor/db/mock"
+)
+
+func newDatabase(t *testing.T) *db.Database {
+	return mock.NewMockedDB(t)
+}
@@ -0,0 +1,7 @@
+// Package e2e holds an end-to-end test of the churner and checker together,
+// against an in-process ACME CA which publishes CRLs to an in-memory bucket.
+//
+// By default the test uses the in-process DynamoDB mock. Run it with
+// `-tags integration` to use DynamoDB Local on localhost:8000 instead, with
+// the tables from db/create_table.sh.
+package e2e
@@ -0,0 +1,78 @@
+package e2e
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/checker"
+	"github.com/letsencrypt/crl-monitor/checker/expiry/mock"
+	"github.com/letsencrypt/crl-monitor/churner"
+	"github.com/letsencrypt/crl-monitor/db"
+	"github.com/letsencrypt/crl-monitor/metrics"
+	notifymock "github.com/letsencrypt/crl-monitor/notify/mock"
+	storagemock "github.com/letsencrypt/crl-monitor/storage/mock"
+)
+
+// TestChurnThenCheck churns certificates from a fake CA, has it publish a CRL
+// with them revoked, and checks the CRL, which should mark them as seen.
+func TestChurnThenCheck(t *testing.T) {
+	ctx := context.Background()
+	baseDomain := "revoked.invalid"
+	bucket := storagemock.NewBucket(t, "crls")
+	dns := &churner.MemoryDNSProvider{}
+	ca := newFakeCA(t, dns, baseDomain, bucket)
+	database := newDatabase(t)
+
+	variants, err := churner.ParseVariants(churner.DefaultVariants)
+	require.NoError(t, err)
+	solver := &churner.ZoneDNS01Solver{Provider: dns, Zone: baseDomain}
+	notifier := &notifymock.Recorder{}
+	c, err := churner.New(baseDomain, ca.Directory(), solver, variants, nil, database, time.Now().Add(-time.Hour), churner.DefaultInterval, metrics.Discard, notifier)
+	require.NoError(t, err)
+
+	require.NoError(t, c.RegisterAccount(ctx))
+	require.NoError(t, c.Churn(ctx))
+
+	issued := ca.Issued()
+	require.Len(t, issued, len(variants))
+	unseen, err := database.GetCertsForIDP(ctx, ca.CRLURL())
+	require.NoError(t, err)
+	for _, cert := range issued {
+		require.Contains(t, unseen, db.NewCertKey(cert.SerialNumber).SerialString())
+	}
+
+	// The checker runs when the CA uploads its next CRL
+	ca.PublishCRL()
+	check := checker.New(checker.Config{
+		DB:                      database,
+		Storage:                 bucket.Storage(),
+		Fetcher:                 &mock.Fetcher{},
+		FetchConcurrency:        1,
+		AgeLimit:                24 * time.Hour,
+		Issuers:                 []*x509.Certificate{ca.issuer},
+		ReaddWindow:             4,
+		RevocationTimeTolerance: 5 * time.Minute,
+		Notifier:                notifier,
+	})
+	require.NoError(t, check.Check(ctx, "crls", ca.Object(), nil))
+
+	unseen, err = database.GetCertsForIDP(ctx, ca.CRLURL())
+	require.NoError(t, err)
+	for _, cert := range issued {
+		require.NotContains(t, unseen, db.NewCertKey(cert.SerialNumber).SerialString())
+	}
+
+	missing, err := c.CheckMissing(ctx)
+	require.NoError(t, err)
+	require.Empty(t, missing)
+	require.Empty(t, notifier.Alerts())
+
+	// The DNS-01 records were all cleaned up
+	records, err := dns.GetRecords(ctx, baseDomain)
+	require.NoError(t, err)
+	require.Empty(t, records)
+}
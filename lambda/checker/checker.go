@@ -7,22 +7,31 @@ import (
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 
 	"github.com/letsencrypt/crl-monitor/checker"
+	"github.com/letsencrypt/crl-monitor/logging"
 )
 
 func HandleRequest(c *checker.Checker) func(ctx context.Context, event events.S3Event) error {
 	return func(ctx context.Context, event events.S3Event) error {
+		if lc, ok := lambdacontext.FromContext(ctx); ok {
+			ctx, _ = logging.With(ctx, logging.RequestID, lc.AwsRequestID)
+		}
+
 		var err error
 		for _, record := range event.Records {
 			record := record
-			err = errors.Join(err, c.Check(ctx, record.S3.Bucket.Name, record.S3.Object.Key, &record.S3.Object.VersionID))
+			// Check logs its own errors, with their severity
+			checkErr := c.Check(ctx, record.S3.Bucket.Name, record.S3.Object.Key, &record.S3.Object.VersionID)
+			err = errors.Join(err, checkErr)
 		}
 		return err
 	}
 }
 
 func main() {
+	logging.Setup()
 	ctx := context.Background()
 
 	c, err := checker.NewFromEnv(ctx)
@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 
 	"github.com/letsencrypt/crl-monitor/churner"
+	"github.com/letsencrypt/crl-monitor/logging"
 )
 
 // HandleRequest returns lambda handler responsible for both of Churner's
@@ -17,6 +19,11 @@ import (
 // on at least one CRL and been removed from the db by the Checker).
 func HandleRequest(c *churner.Churner) func(context.Context) error {
 	return func(ctx context.Context) error {
+		ctx, logger := logging.With(ctx)
+		if lc, ok := lambdacontext.FromContext(ctx); ok {
+			ctx, logger = logging.With(ctx, logging.RequestID, lc.AwsRequestID)
+		}
+
 		// Part 1: Issue a certificate, immediately revoke it, and
 		// insert a database entry indicating when it was issued and revoked.
 		err := c.RegisterAccount(ctx)
@@ -38,9 +45,12 @@ func HandleRequest(c *churner.Churner) func(context.Context) error {
 			return fmt.Errorf("checking for missing certs: %w", err)
 		}
 		if len(missing) != 0 {
-			log.Print("Certificates didn't appear in CRL in time:")
 			for _, missed := range missing {
-				log.Printf("Cert serial %x revoked at %s (%s ago)", missed.SerialNumber, missed.RevocationTime, time.Since(missed.RevocationTime))
+				logger.Error("certificate didn't appear in CRL in time",
+					logging.Serial, missed.SerialString(),
+					logging.IDP, missed.CRLDistributionPoint,
+					"revocationTime", missed.RevocationTime,
+					"age", time.Since(missed.RevocationTime).String())
 			}
 			return fmt.Errorf("missing %d certificates from CRL", len(missing))
 		}
@@ -50,6 +60,7 @@ func HandleRequest(c *churner.Churner) func(context.Context) error {
 }
 
 func main() {
+	logging.Setup()
 	ctx := context.Background()
 
 	c, err := churner.NewFromEnv(ctx)
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+
+	"github.com/letsencrypt/crl-monitor/checker"
+	"github.com/letsencrypt/crl-monitor/cmd"
+	"github.com/letsencrypt/crl-monitor/logging"
+)
+
+const S3CRLBucket cmd.EnvVar = "S3_CRL_BUCKET"
+
+// HandleRequest returns a lambda handler, run on a schedule, which checks that
+// every issuer's shards are all still being published.
+func HandleRequest(s *checker.Sweeper, bucket string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if lc, ok := lambdacontext.FromContext(ctx); ok {
+			ctx, _ = logging.With(ctx, logging.RequestID, lc.AwsRequestID)
+		}
+		return s.Sweep(ctx, bucket, time.Now())
+	}
+}
+
+func main() {
+	logging.Setup()
+	bucket := S3CRLBucket.MustRead("S3 CRL bucket name")
+
+	ctx := context.Background()
+
+	s, err := checker.NewSweeperFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("Error creating Sweeper: %v", err)
+	}
+
+	lambda.StartWithOptions(HandleRequest(s, bucket), lambda.WithContext(ctx))
+}
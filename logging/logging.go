@@ -0,0 +1,58 @@
+// Package logging sets up structured JSON logging, and carries a logger with
+// request-scoped attributes through a context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Attribute keys used consistently across components, so that every line
+// about one CRL or one churn run can be found with a single query.
+const (
+	Bucket    = "bucket"
+	Object    = "object"
+	Version   = "version"
+	CRLNumber = "crlNumber"
+	Issuer    = "issuer"
+	IDP       = "idp"
+	Serial    = "serial"
+	RequestID = "requestID"
+)
+
+// Setup makes the default logger, which the log package also writes through,
+// write JSON to stderr.
+func Setup() {
+	slog.SetDefault(New(os.Stderr))
+}
+
+// New returns a logger writing JSON to w.
+func New(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, nil))
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if
+// there isn't one.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+	return logger
+}
+
+// With adds attributes to the logger carried by ctx, returning both the new
+// context and the new logger.
+func With(ctx context.Context, args ...any) (context.Context, *slog.Logger) {
+	logger := FromContext(ctx).With(args...)
+	return WithLogger(ctx, logger), logger
+}
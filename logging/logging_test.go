@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWith(t *testing.T) {
+	require.Equal(t, slog.Default(), FromContext(context.Background()))
+
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), New(&buf))
+	ctx, _ = With(ctx, Bucket, "crls", Object, "123/0.crl")
+	_, logger := With(ctx, Version, "v1")
+
+	// Attributes added to a derived context don't affect the parent
+	FromContext(ctx).Info("parent")
+	logger.Info("child", CRLNumber, "7")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var parent, child map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &parent))
+	require.NoError(t, json.Unmarshal(lines[1], &child))
+
+	require.Equal(t, "parent", parent["msg"])
+	require.Equal(t, "crls", parent[Bucket])
+	require.NotContains(t, parent, Version)
+
+	require.Equal(t, "child", child["msg"])
+	require.Equal(t, "123/0.crl", child[Object])
+	require.Equal(t, "v1", child[Version])
+	require.Equal(t, "7", child[CRLNumber])
+}
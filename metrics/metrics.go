@@ -0,0 +1,117 @@
+// Package metrics emits numeric metrics from the checker and churner.
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Namespace is the CloudWatch namespace all metrics are emitted under.
+const Namespace = "CRLMonitor"
+
+// Unit is a CloudWatch metric unit.
+type Unit string
+
+const (
+	Count        Unit = "Count"
+	Seconds      Unit = "Seconds"
+	Milliseconds Unit = "Milliseconds"
+)
+
+// Metric is a single named value.
+type Metric struct {
+	Name  string
+	Value float64
+	Unit  Unit
+}
+
+// Sink receives metrics. Emitting is best-effort, so it can't fail.
+type Sink interface {
+	Emit(dimensions map[string]string, metrics ...Metric)
+}
+
+// Discard is a Sink which drops all metrics.
+var Discard Sink = discard{}
+
+type discard struct{}
+
+func (discard) Emit(map[string]string, ...Metric) {}
+
+// EMF is a Sink which writes each set of metrics as a line of JSON in
+// CloudWatch Embedded Metric Format. Lambda sends stdout to CloudWatch Logs,
+// which extracts the metrics. Use NewEMF to obtain one.
+type EMF struct {
+	mu        sync.Mutex
+	w         io.Writer
+	namespace string
+	clk       func() time.Time
+}
+
+func NewEMF(w io.Writer, namespace string) *EMF {
+	return &EMF{w: w, namespace: namespace, clk: time.Now}
+}
+
+// The structure of the _aws metadata in Embedded Metric Format, see
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+type emfMetadata struct {
+	Timestamp         int64           `json:"Timestamp"`
+	CloudWatchMetrics []emfDirectives `json:"CloudWatchMetrics"`
+}
+
+type emfDirectives struct {
+	Namespace  string           `json:"Namespace"`
+	Dimensions [][]string       `json:"Dimensions"`
+	Metrics    []emfDefinitions `json:"Metrics"`
+}
+
+type emfDefinitions struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit,omitempty"`
+}
+
+// Emit writes metrics with dimensions, which are all used together as a
+// single dimension set.
+func (e *EMF) Emit(dimensions map[string]string, metrics ...Metric) {
+	if len(metrics) == 0 {
+		return
+	}
+
+	// Dimensions and metric values are top-level members, next to _aws
+	doc := make(map[string]any, len(dimensions)+len(metrics)+1)
+	definitions := make([]emfDefinitions, 0, len(metrics))
+	for _, metric := range metrics {
+		doc[metric.Name] = metric.Value
+		definitions = append(definitions, emfDefinitions{Name: metric.Name, Unit: metric.Unit})
+	}
+	for name, value := range dimensions {
+		doc[name] = value
+	}
+	// An empty dimension set must still be an array, not null
+	dimensionSet := append([]string{}, slices.Sorted(maps.Keys(dimensions))...)
+	doc["_aws"] = emfMetadata{
+		Timestamp: e.clk().UnixMilli(),
+		CloudWatchMetrics: []emfDirectives{{
+			Namespace:  e.namespace,
+			Dimensions: [][]string{dimensionSet},
+			Metrics:    definitions,
+		}},
+	}
+
+	line, err := json.Marshal(doc)
+	if err != nil {
+		slog.Error("error marshalling metrics", "error", err)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(line, '\n'))
+	if err != nil {
+		slog.Error("error writing metrics", "error", err)
+	}
+}
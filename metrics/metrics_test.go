@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEMF(t *testing.T) {
+	var buf bytes.Buffer
+	emf := NewEMF(&buf, "Test")
+	emf.clk = func() time.Time { return time.UnixMilli(1700000000123) }
+
+	emf.Emit(map[string]string{"Issuer": "123", "Host": "a"},
+		Metric{Name: "NumEntries", Value: 42, Unit: Count},
+		Metric{Name: "Latency", Value: 1.5, Unit: Seconds},
+	)
+	emf.Emit(nil, Metric{Name: "Untyped", Value: 1})
+	// Nothing is written without any metrics
+	emf.Emit(map[string]string{"Issuer": "123"})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	require.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1700000000123,
+			"CloudWatchMetrics": [{
+				"Namespace": "Test",
+				"Dimensions": [["Host", "Issuer"]],
+				"Metrics": [{"Name": "NumEntries", "Unit": "Count"}, {"Name": "Latency", "Unit": "Seconds"}]
+			}]
+		},
+		"Issuer": "123",
+		"Host": "a",
+		"NumEntries": 42,
+		"Latency": 1.5
+	}`, string(lines[0]))
+	require.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1700000000123,
+			"CloudWatchMetrics": [{"Namespace": "Test", "Dimensions": [[]], "Metrics": [{"Name": "Untyped"}]}]
+		},
+		"Untyped": 1
+	}`, string(lines[1]))
+}
@@ -0,0 +1,40 @@
+package mock
+
+import (
+	"sync"
+
+	"github.com/letsencrypt/crl-monitor/metrics"
+)
+
+// Emitted is one call to Recorder.Emit
+type Emitted struct {
+	Dimensions map[string]string
+	Metrics    []metrics.Metric
+}
+
+// Recorder is a metrics.Sink which keeps everything emitted, for tests
+type Recorder struct {
+	mu      sync.Mutex
+	emitted []Emitted
+}
+
+func (r *Recorder) Emit(dimensions map[string]string, metrics ...metrics.Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emitted = append(r.emitted, Emitted{Dimensions: dimensions, Metrics: metrics})
+}
+
+// Values returns every value emitted for the named metric, in order
+func (r *Recorder) Values(name string) []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var values []float64
+	for _, emitted := range r.emitted {
+		for _, metric := range emitted.Metrics {
+			if metric.Name == name {
+				values = append(values, metric.Value)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,28 @@
+package mock
+
+import (
+	"context"
+	"sync"
+
+	"github.com/letsencrypt/crl-monitor/notify"
+)
+
+// Recorder is a notify.Notifier which keeps every alert, for tests
+type Recorder struct {
+	mu     sync.Mutex
+	alerts []notify.Alert
+}
+
+func (r *Recorder) Notify(_ context.Context, alert notify.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+// Alerts returns every alert sent, in order
+func (r *Recorder) Alerts() []notify.Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]notify.Alert(nil), r.alerts...)
+}
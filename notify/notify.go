@@ -0,0 +1,88 @@
+// Package notify sends alerts about CRL violations found by the checker and
+// churner, in addition to them being logged and returned as errors.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/letsencrypt/crl-monitor/cmd"
+)
+
+const (
+	SNSTopicEnv   cmd.EnvVar = "NOTIFY_SNS_TOPIC"
+	WebhookURLEnv cmd.EnvVar = "NOTIFY_WEBHOOK_URL"
+)
+
+// Alert is the payload sent for a violation.
+type Alert struct {
+	// Source is the component which found the violation: checker, churner or
+	// sweeper
+	Source string `json:"source"`
+	// Type is the kind of violation, such as EarlyRemovalError
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Bucket  string `json:"bucket,omitempty"`
+	// Shard is the S3 object of the CRL shard, or its IssuingDistributionPoint
+	// if the object isn't known.
+	Shard string `json:"shard"`
+	// Versions are the S3 versions of the shard involved, oldest first.
+	Versions []string `json:"versions,omitempty"`
+	// Serials are the certificates involved, as hex.
+	Serials []string `json:"serials,omitempty"`
+}
+
+// Notifier sends alerts somewhere a human will see them.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Discard is a Notifier which drops all alerts.
+var Discard Notifier = discard{}
+
+type discard struct{}
+
+func (discard) Notify(context.Context, Alert) error { return nil }
+
+// Multi is a Notifier which sends each alert to all of its Notifiers.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, alert Alert) error {
+	var errs []error
+	for _, n := range m {
+		errs = append(errs, n.Notify(ctx, alert))
+	}
+	return errors.Join(errs...)
+}
+
+// FromEnv returns a Notifier for each of NOTIFY_SNS_TOPIC and
+// NOTIFY_WEBHOOK_URL which is set, or Discard if neither are.
+func FromEnv(ctx context.Context) (Notifier, error) {
+	var notifiers Multi
+
+	topic, hasTopic := SNSTopicEnv.LookupEnv()
+	if hasTopic {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("creating AWS config: %w", err)
+		}
+		notifiers = append(notifiers, NewSNS(cfg, topic))
+	}
+
+	url, hasURL := WebhookURLEnv.LookupEnv()
+	if hasURL {
+		notifiers = append(notifiers, NewWebhook(url))
+	}
+
+	switch len(notifiers) {
+	case 0:
+		return Discard, nil
+	case 1:
+		return notifiers[0], nil
+	default:
+		return notifiers, nil
+	}
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/stretchr/testify/require"
+)
+
+var testAlert = Alert{
+	Source:   "checker",
+	Type:     "EarlyRemovalError",
+	Message:  "early removal of 1 certificates detected!",
+	Bucket:   "crl-test",
+	Shard:    "123/0.crl",
+	Versions: []string{"v1", "v2"},
+	Serials:  []string{"000000000000000000000000000000000002"},
+}
+
+func TestWebhook(t *testing.T) {
+	var received Alert
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(server.URL)
+	require.NoError(t, webhook.Notify(context.Background(), testAlert))
+	require.Equal(t, testAlert, received)
+
+	status = http.StatusInternalServerError
+	require.ErrorContains(t, webhook.Notify(context.Background(), testAlert), "http status 500")
+}
+
+type mockSNS struct {
+	t     *testing.T
+	input *sns.PublishInput
+}
+
+func (m *mockSNS) Publish(_ context.Context, params *sns.PublishInput, opts ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	require.Empty(m.t, opts, "options not supported")
+	m.input = params
+	return &sns.PublishOutput{}, nil
+}
+
+func TestSNS(t *testing.T) {
+	client := &mockSNS{t: t}
+	s := &SNS{client: client, topic: "arn:aws:sns:us-west-2:123456789012:crl-monitor"}
+	require.NoError(t, s.Notify(context.Background(), testAlert))
+
+	require.Equal(t, "arn:aws:sns:us-west-2:123456789012:crl-monitor", *client.input.TopicArn)
+	require.Equal(t, "CRL monitor checker: EarlyRemovalError on 123/0.crl", *client.input.Subject)
+	var received Alert
+	require.NoError(t, json.Unmarshal([]byte(*client.input.Message), &received))
+	require.Equal(t, testAlert, received)
+
+	// Subjects are truncated to what SNS allows
+	long := testAlert
+	long.Shard = strings.Repeat("a", 200)
+	require.NoError(t, s.Notify(context.Background(), long))
+	require.Len(t, *client.input.Subject, maxSubject)
+}
+
+type failing struct{}
+
+func (failing) Notify(context.Context, Alert) error { return errors.New("failed") }
+
+func TestMulti(t *testing.T) {
+	client := &mockSNS{t: t}
+	multi := Multi{failing{}, &SNS{client: client, topic: "topic"}}
+
+	// One failing notifier doesn't stop the others
+	require.ErrorContains(t, multi.Notify(context.Background(), testAlert), "failed")
+	require.NotNil(t, client.input)
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// snsclient is the subset of sns.Client used, for tests
+type snsclient interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SNS is a Notifier which publishes each alert as JSON to an SNS topic. Use
+// NewSNS to obtain one.
+type SNS struct {
+	client snsclient
+	topic  string
+}
+
+func NewSNS(cfg aws.Config, topicARN string) *SNS {
+	return &SNS{client: sns.NewFromConfig(cfg), topic: topicARN}
+}
+
+// maxSubject is the longest subject SNS allows
+const maxSubject = 100
+
+func (s *SNS) Notify(ctx context.Context, alert Alert) error {
+	message, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("marshalling alert: %w", err)
+	}
+
+	subject := fmt.Sprintf("CRL monitor %s: %s on %s", alert.Source, alert.Type, alert.Shard)
+	if len(subject) > maxSubject {
+		subject = subject[:maxSubject]
+	}
+
+	_, err = s.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(s.topic),
+		Subject:  aws.String(subject),
+		Message:  aws.String(string(message)),
+	})
+	if err != nil {
+		return fmt.Errorf("publishing to SNS topic %s: %w", s.topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Webhook is a Notifier which POSTs each alert as JSON to a URL. Use
+// NewWebhook to obtain one.
+type Webhook struct {
+	client *http.Client
+	url    string
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{client: &http.Client{Timeout: 30 * time.Second}, url: url}
+}
+
+func (w *Webhook) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("marshalling alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CRL-Monitor/0.1")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting alert to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("posting alert to webhook: http status %d (%s)", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
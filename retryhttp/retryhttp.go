@@ -44,7 +44,12 @@ func Get(ctx context.Context, url string) ([]byte, error) {
 		if err == nil {
 			return body, nil
 		}
-		time.Sleep(time.Duration(backoff) * time.Millisecond)
+		// Stop retrying once the context is done, rather than sleeping past it
+		select {
+		case <-time.After(time.Duration(backoff) * time.Millisecond):
+		case <-ctx.Done():
+			return nil, err
+		}
 	}
 	return nil, err
 }
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// scraperTimeFormat is the format of the timestamp cmd/scraper puts in
+// filenames, which is the S3 LastModified time of that version in UTC.
+const scraperTimeFormat = "2006-01-02T15:04:05"
+
+// Filesystem reads CRLs from a directory written by cmd/scraper, as an offline
+// alternative to Storage. Each version of a shard is a file named
+// <short>-<shard>-<timestamp>-<versionID>.crl, and versions are ordered by
+// their timestamp. The timestamps only have second precision, and versions
+// uploaded in the same second are ordered by version ID, which is arbitrary.
+//
+// Keys use the same object paths as S3, <issuer prefix>/<shard>.crl. The
+// Bucket is ignored, as a directory only holds files from a single bucket.
+//
+// The directory is read once, by NewFilesystem, so files added later aren't
+// seen.
+type Filesystem struct {
+	dir        string
+	shortNames map[string]string
+	// versions holds every version of each object path, newest first,
+	// matching the order ListObjectVersions uses
+	versions map[string][]fileVersion
+}
+
+// NewFilesystem returns a Filesystem reading from dir. The shortNames map
+// translates the issuer prefix of an object path, which is the issuer's name
+// ID, to the short issuer name (like "r3") used in filenames.
+func NewFilesystem(dir string, shortNames map[string]string) (*Filesystem, error) {
+	f := &Filesystem{dir: dir, shortNames: shortNames, versions: make(map[string][]fileVersion)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if v, ok := f.parseName(entry.Name()); ok {
+			f.versions[v.object] = append(f.versions[v.object], v)
+		}
+	}
+
+	for _, versions := range f.versions {
+		slices.SortFunc(versions, func(a, b fileVersion) int {
+			if c := b.modified.Compare(a.modified); c != 0 {
+				return c
+			}
+			return strings.Compare(b.versionID, a.versionID)
+		})
+	}
+	return f, nil
+}
+
+type fileVersion struct {
+	object    string
+	name      string
+	versionID string
+	modified  time.Time
+}
+
+// parseName splits a filename written by cmd/scraper into the object path it
+// was a version of and the version's details.
+func (f *Filesystem) parseName(name string) (fileVersion, bool) {
+	for prefix, short := range f.shortNames {
+		rest, ok := strings.CutPrefix(name, short+"-")
+		if !ok {
+			continue
+		}
+		shard, rest, ok := strings.Cut(rest, "-")
+		if !ok || shard == "" || strings.Trim(shard, "0123456789") != "" || len(rest) <= len(scraperTimeFormat)+1 {
+			continue
+		}
+		modified, err := time.Parse(scraperTimeFormat, rest[:len(scraperTimeFormat)])
+		if err != nil || rest[len(scraperTimeFormat)] != '-' {
+			continue
+		}
+		versionID, ok := strings.CutSuffix(rest[len(scraperTimeFormat)+1:], ".crl")
+		if !ok || versionID == "" {
+			continue
+		}
+		return fileVersion{
+			object:    fmt.Sprintf("%s/%s.crl", prefix, shard),
+			name:      name,
+			versionID: versionID,
+			modified:  modified,
+		}, true
+	}
+	return fileVersion{}, false
+}
+
+// objectVersions returns every version of the object, newest first.
+func (f *Filesystem) objectVersions(key Key) ([]fileVersion, error) {
+	prefix, _, found := strings.Cut(key.Object, "/")
+	if !found || !strings.HasSuffix(key.Object, ".crl") {
+		return nil, fmt.Errorf("object path not in the form <issuer>/<shard>.crl: %s", key.Object)
+	}
+	if _, ok := f.shortNames[prefix]; !ok {
+		return nil, fmt.Errorf("no short name for issuer prefix %s", prefix)
+	}
+	return f.versions[key.Object], nil
+}
+
+// Objects returns the sorted object paths of every shard with at least one
+// version in the directory.
+func (f *Filesystem) Objects() []string {
+	return slices.Sorted(maps.Keys(f.versions))
+}
+
+// List returns the newest version of every object in the directory whose
+// name starts with prefix. The bucket is ignored.
+func (f *Filesystem) List(_ context.Context, _, prefix string) ([]Object, error) {
+	var objects []Object
+	for _, object := range f.Objects() {
+		if strings.HasPrefix(object, prefix) {
+			objects = append(objects, Object{Key: object, LastModified: f.versions[object][0].modified})
+		}
+	}
+	return objects, nil
+}
+
+// Versions returns the version IDs of an object, newest first.
+func (f *Filesystem) Versions(key Key) ([]string, error) {
+	versions, err := f.objectVersions(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, v := range versions {
+		ids = append(ids, v.versionID)
+	}
+	return ids, nil
+}
+
+// Fetch gets a CRL from the directory at a particular version.
+// If version is nil, the newest version is returned.
+// Returns the retrieved DER CRL bytes and what VersionID it was.
+func (f *Filesystem) Fetch(_ context.Context, key Key) ([]byte, string, error) {
+	versions, err := f.objectVersions(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, v := range versions {
+		if key.Version == nil || *key.Version == v.versionID {
+			body, err := os.ReadFile(filepath.Join(f.dir, v.name))
+			if err != nil {
+				return nil, "", fmt.Errorf("reading CRL %s version %s: %w", key.Object, v.versionID, err)
+			}
+			return body, v.versionID, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("CRL %s version %s not found in %s", key.Object, key.VersionString(), f.dir)
+}
+
+// Uploaded returns when a particular version of a CRL was uploaded, which is
+// the time in its file name.
+func (f *Filesystem) Uploaded(_ context.Context, key Key) (time.Time, error) {
+	versions, err := f.objectVersions(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, v := range versions {
+		if key.Version == nil || *key.Version == v.versionID {
+			return v.modified, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("CRL %s version %s not found in %s", key.Object, key.VersionString(), f.dir)
+}
+
+// Previous returns the previous version of a CRL shard, which can then be fetched.
+func (f *Filesystem) Previous(_ context.Context, key Key) (string, error) {
+	if key.Version == nil {
+		return "", fmt.Errorf("Previous called with no Version")
+	}
+
+	versions, err := f.objectVersions(key)
+	if err != nil {
+		return "", err
+	}
+
+	idx := slices.IndexFunc(versions, func(v fileVersion) bool { return v.versionID == *key.Version })
+	if idx == -1 {
+		return "", fmt.Errorf("current version wasn't found: object:%s version:%s", key.Object, key.VersionString())
+	}
+	if idx == len(versions)-1 {
+		return "", fmt.Errorf("%w: object:%s version:%s", ErrNoPreviousVersion, key.Object, key.VersionString())
+	}
+
+	return versions[idx+1].versionID, nil
+}
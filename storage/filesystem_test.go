@@ -0,0 +1,121 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+
+	"github.com/letsencrypt/crl-monitor/storage"
+)
+
+func TestFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"r3-1-2026-06-01T00:00:00-aaa.crl":  {0x01},
+		"r3-1-2026-06-01T06:00:00-bbb.crl":  {0x02},
+		"r3-1-2026-06-01T12:00:00-ccc.crl":  {0x03},
+		"r3-10-2026-06-01T18:00:00-ddd.crl": {0x10},
+		"e1-1-2026-06-01T18:00:00-eee.crl":  {0xe1},
+		"README":                            {0x00},
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+	}
+
+	fs, err := storage.NewFilesystem(dir, map[string]string{"123": "r3", "456": "e1"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name        string
+		object      string
+		version     *string
+		expectedVer string
+		expectedCRL []byte
+	}{
+		{name: "newest", object: "123/1.crl", expectedVer: "ccc", expectedCRL: []byte{0x03}},
+		{name: "by version", object: "123/1.crl", version: aws.String("aaa"), expectedVer: "aaa", expectedCRL: []byte{0x01}},
+		{name: "shard sharing a prefix", object: "123/10.crl", expectedVer: "ddd", expectedCRL: []byte{0x10}},
+		{name: "other issuer", object: "456/1.crl", expectedVer: "eee", expectedCRL: []byte{0xe1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			crl, version, err := fs.Fetch(ctx, storage.Key{Object: tt.object, Version: tt.version})
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedVer, version)
+			require.Equal(t, tt.expectedCRL, crl)
+		})
+	}
+
+	prev, err := fs.Previous(ctx, storage.Key{Object: "123/1.crl", Version: aws.String("ccc")})
+	require.NoError(t, err)
+	require.Equal(t, "bbb", prev)
+	prev, err = fs.Previous(ctx, storage.Key{Object: "123/1.crl", Version: aws.String("bbb")})
+	require.NoError(t, err)
+	require.Equal(t, "aaa", prev)
+
+	for _, tt := range []struct {
+		name    string
+		object  string
+		version string
+	}{
+		{name: "no previous", object: "123/1.crl", version: "aaa"},
+		{name: "singleton", object: "123/10.crl", version: "ddd"},
+		{name: "not a real version", object: "123/1.crl", version: "moo-cow"},
+		{name: "unknown issuer", object: "789/1.crl", version: "aaa"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, err := fs.Previous(ctx, storage.Key{Object: tt.object, Version: &tt.version})
+			require.Error(t, err)
+			require.Equal(t, "", prev)
+		})
+	}
+
+	_, err = fs.Previous(ctx, storage.Key{Object: "123/1.crl", Version: aws.String("aaa")})
+	require.ErrorIs(t, err, storage.ErrNoPreviousVersion)
+
+	_, _, err = fs.Fetch(ctx, storage.Key{Object: "123/2.crl"})
+	require.Error(t, err)
+
+	uploaded, err := fs.Uploaded(ctx, storage.Key{Object: "123/1.crl", Version: aws.String("bbb")})
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 6, 1, 6, 0, 0, 0, time.UTC), uploaded)
+}
+
+func TestFilesystemListing(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"r3-1-2026-06-01T06:00:00-bbb.crl",
+		"r3-1-2026-06-01T00:00:00-aaa.crl",
+		"r3-1-2026-06-01T06:00:00-abc.crl",
+		"r3-10-2026-06-01T18:00:00-ddd.crl",
+		"stg-r3-1-2026-06-01T18:00:00-eee.crl",
+		"r3-x-2026-06-01T18:00:00-fff.crl",
+		"r3-1-yesterday-ggg.crl",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	fs, err := storage.NewFilesystem(dir, map[string]string{"123": "r3", "456": "stg-r3"})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"123/1.crl", "123/10.crl", "456/1.crl"}, fs.Objects())
+
+	versions, err := fs.Versions(storage.Key{Object: "123/1.crl"})
+	require.NoError(t, err)
+	// Versions from the same second are ordered by version ID
+	require.Equal(t, []string{"bbb", "abc", "aaa"}, versions)
+
+	listed, err := fs.List(context.Background(), "", "123/")
+	require.NoError(t, err)
+	require.Equal(t, []storage.Object{
+		{Key: "123/1.crl", LastModified: time.Date(2026, 6, 1, 6, 0, 0, 0, time.UTC)},
+		{Key: "123/10.crl", LastModified: time.Date(2026, 6, 1, 18, 0, 0, 0, time.UTC)},
+	}, listed)
+
+	_, err = storage.NewFilesystem(filepath.Join(dir, "missing"), nil)
+	require.Error(t, err)
+}
@@ -5,7 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -17,19 +20,28 @@ import (
 
 // MockObject is a single version of an object
 type MockObject struct {
-	VersionID string
-	Data      []byte
+	VersionID    string
+	Data         []byte
+	LastModified time.Time
 }
 
 // New mock storage.  Takes a bucket and mock data.
 // The data is a map of object name -> list of versions
 func New(t *testing.T, bucket string, mockData map[string][]MockObject) *storage.Storage {
-	return &storage.Storage{S3Client: &s3mock{t: t, bucket: bucket, mockData: mockData}}
+	return NewPaginated(t, bucket, 0, mockData)
+}
+
+// NewPaginated is like New, but ListObjectVersions returns at most pageSize
+// versions per call, like S3 does when there are more than 1000 versions.
+// A pageSize of 0 returns all versions in a single page.
+func NewPaginated(t *testing.T, bucket string, pageSize int, mockData map[string][]MockObject) *storage.Storage {
+	return &storage.Storage{S3Client: &s3mock{t: t, bucket: bucket, pageSize: pageSize, mockData: mockData}}
 }
 
 type s3mock struct {
 	t        *testing.T
 	bucket   string
+	pageSize int
 	mockData map[string][]MockObject
 }
 
@@ -60,6 +72,33 @@ func (s *s3mock) GetObject(ctx context.Context, input *s3.GetObjectInput, opts .
 	return nil, fmt.Errorf("object version not found: %s %s", *input.Key, *versionID)
 }
 
+func (s *s3mock) HeadObject(ctx context.Context, input *s3.HeadObjectInput, opts ...func(options *s3.Options)) (*s3.HeadObjectOutput, error) {
+	require.Empty(s.t, opts, "options not supported")
+	require.NotNil(s.t, input)
+	require.NotNil(s.t, input.Bucket)
+	require.Equal(s.t, s.bucket, *input.Bucket)
+	require.NotNil(s.t, input.Key)
+
+	object, ok := s.mockData[*input.Key]
+	require.True(s.t, ok, "object not found: %s", *input.Key)
+
+	versionID := input.VersionId
+	if input.VersionId == nil {
+		versionID = aws.String(object[0].VersionID)
+	}
+
+	for _, version := range object {
+		if version.VersionID == *versionID {
+			return &s3.HeadObjectOutput{
+				LastModified: aws.Time(version.LastModified),
+				VersionId:    versionID,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("object version not found: %s %s", *input.Key, *versionID)
+}
+
 func (s *s3mock) ListObjectVersions(ctx context.Context, input *s3.ListObjectVersionsInput, opts ...func(options *s3.Options)) (*s3.ListObjectVersionsOutput, error) {
 	require.Empty(s.t, opts, "options not supported")
 	require.NotNil(s.t, input)
@@ -70,10 +109,102 @@ func (s *s3mock) ListObjectVersions(ctx context.Context, input *s3.ListObjectVer
 	object, ok := s.mockData[*input.Prefix]
 	require.True(s.t, ok, "object not found: %s", *input.Prefix)
 
-	resp := &s3.ListObjectVersionsOutput{}
-	for _, version := range object {
-		resp.Versions = append(resp.Versions, types.ObjectVersion{VersionId: aws.String(version.VersionID)})
+	// Resume after the marker version if this is a follow-up page
+	start := 0
+	if input.VersionIdMarker != nil {
+		require.NotNil(s.t, input.KeyMarker)
+		require.Equal(s.t, *input.Prefix, *input.KeyMarker)
+		start = -1
+		for i, version := range object {
+			if version.VersionID == *input.VersionIdMarker {
+				start = i + 1
+				break
+			}
+		}
+		require.NotEqual(s.t, -1, start, "version marker not found: %s", *input.VersionIdMarker)
+	}
+
+	end := len(object)
+	if s.pageSize > 0 && start+s.pageSize < end {
+		end = start + s.pageSize
+	}
+
+	resp := &s3.ListObjectVersionsOutput{IsTruncated: aws.Bool(end < len(object))}
+	for _, version := range object[start:end] {
+		resp.Versions = append(resp.Versions, types.ObjectVersion{Key: input.Prefix, VersionId: aws.String(version.VersionID)})
+	}
+	if *resp.IsTruncated {
+		resp.NextKeyMarker = input.Prefix
+		resp.NextVersionIdMarker = aws.String(object[end-1].VersionID)
+	}
+
+	return resp, nil
+}
+
+func (s *s3mock) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, opts ...func(options *s3.Options)) (*s3.ListObjectsV2Output, error) {
+	require.Empty(s.t, opts, "options not supported")
+	require.NotNil(s.t, input)
+	require.NotNil(s.t, input.Bucket)
+	require.Equal(s.t, s.bucket, *input.Bucket)
+	require.NotNil(s.t, input.Prefix)
+
+	var keys []string
+	for key := range s.mockData {
+		// The continuation token is the last key of the previous page
+		if strings.HasPrefix(key, *input.Prefix) && (input.ContinuationToken == nil || key > *input.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	resp := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	if s.pageSize > 0 && len(keys) > s.pageSize {
+		keys = keys[:s.pageSize]
+		resp.IsTruncated = aws.Bool(true)
+		resp.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+
+	for _, key := range keys {
+		// The first version is the current one
+		resp.Contents = append(resp.Contents, types.Object{
+			Key:          aws.String(key),
+			LastModified: aws.Time(s.mockData[key][0].LastModified),
+		})
 	}
 
 	return resp, nil
 }
+
+// Bucket is a versioned bucket which objects can be uploaded to while a test
+// runs, standing in for an S3 bucket a CA publishes CRLs to. It isn't safe to
+// upload concurrently with reads from its Storage.
+type Bucket struct {
+	t       *testing.T
+	name    string
+	objects map[string][]MockObject
+	// versions counts uploads, to give each version a unique ID
+	versions int
+}
+
+// NewBucket returns an empty Bucket called name.
+func NewBucket(t *testing.T, name string) *Bucket {
+	return &Bucket{t: t, name: name, objects: make(map[string][]MockObject)}
+}
+
+// Put uploads a new current version of object, returning its version ID.
+func (b *Bucket) Put(object string, data []byte) string {
+	b.versions++
+	versionID := fmt.Sprintf("v%d", b.versions)
+	b.objects[object] = slices.Insert(b.objects[object], 0, MockObject{
+		VersionID:    versionID,
+		Data:         data,
+		LastModified: time.Now(),
+	})
+	return versionID
+}
+
+// Storage returns a Storage reading from the bucket, including objects
+// uploaded after it was returned.
+func (b *Bucket) Storage() *storage.Storage {
+	return New(b.t, b.name, b.objects)
+}
@@ -2,10 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
@@ -13,9 +16,15 @@ import (
 // s3client is fulfilled by s3.Client and is used for mocking in tests
 type s3client interface {
 	GetObject(ctx context.Context, input *s3.GetObjectInput, opts ...func(options *s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, input *s3.HeadObjectInput, opts ...func(options *s3.Options)) (*s3.HeadObjectOutput, error)
 	ListObjectVersions(ctx context.Context, input *s3.ListObjectVersionsInput, opts ...func(options *s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, opts ...func(options *s3.Options)) (*s3.ListObjectsV2Output, error)
 }
 
+// ErrNoPreviousVersion is returned by Previous when the given version is the
+// oldest one.
+var ErrNoPreviousVersion = errors.New("current version found but no previous version")
+
 type Storage struct {
 	S3Client s3client
 }
@@ -26,6 +35,12 @@ type Key struct {
 	Version        *string
 }
 
+// Object is the current version of an object in storage, as returned by List.
+type Object struct {
+	Key          string
+	LastModified time.Time
+}
+
 // VersionString returns the version string or "unknown" if unset.
 func (k Key) VersionString() string {
 	if k.Version == nil {
@@ -66,44 +81,84 @@ func (s *Storage) Fetch(ctx context.Context, key Key) ([]byte, string, error) {
 	return body, *resp.VersionId, err
 }
 
+// Uploaded returns when a particular version of a CRL was uploaded.
+func (s *Storage) Uploaded(ctx context.Context, key Key) (time.Time, error) {
+	resp, err := s.S3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:    &key.Bucket,
+		Key:       &key.Object,
+		VersionId: key.Version,
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("retrieving metadata of CRL %s %s version %s: %w", key.Bucket, key.Object, key.VersionString(), err)
+	}
+	if resp.LastModified == nil {
+		return time.Time{}, fmt.Errorf("CRL %s %s version %s has no LastModified", key.Bucket, key.Object, key.VersionString())
+	}
+	return *resp.LastModified, nil
+}
+
 // Previous returns the previous version of a CRL shard, which can then be fetched.
 func (s *Storage) Previous(ctx context.Context, key Key) (string, error) {
 	if key.Version == nil {
 		return "", fmt.Errorf("Previous called with no Version")
 	}
-	resp, err := s.S3Client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{
+	// S3 returns at most 1000 versions per page, and busy shards can have many
+	// more than that, so walk pages until we find the version after ours.
+	paginator := s3.NewListObjectVersionsPaginator(s.S3Client, &s3.ListObjectVersionsInput{
 		Bucket: &key.Bucket,
 		Prefix: &key.Object,
 	})
-	if err != nil {
-		return "", err
-	}
 
-	var prevVersion *string
 	found := false
-	for _, v := range resp.Versions {
-		if found {
-			prevVersion = v.VersionId
-			break
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("listing versions of %s %s: %w", key.Bucket, key.Object, err)
 		}
 
-		if v.VersionId != nil && *v.VersionId == *key.Version {
-			// This is the version of interest; select the next one
-			found = true
-		}
-	}
+		for _, v := range page.Versions {
+			if found && v.VersionId != nil {
+				return *v.VersionId, nil
+			}
 
-	if (!found || prevVersion == nil) && resp.IsTruncated != nil && *resp.IsTruncated {
-		return "", fmt.Errorf("too many versions and pagination not implemented! bucket:%s object:%s version:%s", key.Bucket, key.Object, key.VersionString())
+			if v.VersionId != nil && *v.VersionId == *key.Version {
+				// This is the version of interest; select the next one
+				found = true
+			}
+		}
 	}
 
 	if !found {
-		return "", fmt.Errorf("current version wasn't found in non-truncated response")
+		return "", fmt.Errorf("current version wasn't found: bucket:%s object:%s version:%s", key.Bucket, key.Object, key.VersionString())
 	}
 
-	if prevVersion == nil {
-		return "", fmt.Errorf("current version found but no previous version")
+	return "", fmt.Errorf("%w: bucket:%s object:%s version:%s", ErrNoPreviousVersion, key.Bucket, key.Object, key.VersionString())
+}
+
+// List returns the current version of every object in a bucket whose name
+// starts with prefix.
+func (s *Storage) List(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	var objects []Object
+	input := &s3.ListObjectsV2Input{
+		Bucket: &bucket,
+		Prefix: &prefix,
 	}
+	for {
+		resp, err := s.S3Client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("listing objects in %s with prefix %s: %w", bucket, prefix, err)
+		}
+
+		for _, obj := range resp.Contents {
+			if obj.Key == nil {
+				continue
+			}
+			objects = append(objects, Object{Key: *obj.Key, LastModified: aws.ToTime(obj.LastModified)})
+		}
 
-	return *prevVersion, nil
+		if !aws.ToBool(resp.IsTruncated) {
+			return objects, nil
+		}
+		input.ContinuationToken = resp.NextContinuationToken
+	}
 }
@@ -2,7 +2,9 @@ package storage_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/stretchr/testify/require"
@@ -156,3 +158,89 @@ func TestStorage(t *testing.T) {
 		})
 	}
 }
+
+func TestPreviousPaginated(t *testing.T) {
+	var versions []mock.MockObject
+	for i := range 10 {
+		versions = append(versions, mock.MockObject{VersionID: fmt.Sprintf("v%d", i)})
+	}
+	// A page size of 3 puts page boundaries between v2/v3, v5/v6 and v8/v9
+	mockStorage := mock.NewPaginated(t, "somebucket", 3, map[string][]mock.MockObject{
+		"123/0.crl": versions,
+	})
+
+	for i := range len(versions) - 1 {
+		t.Run(versions[i].VersionID, func(t *testing.T) {
+			version, err := mockStorage.Previous(context.Background(), storage.Key{
+				Bucket:  "somebucket",
+				Object:  "123/0.crl",
+				Version: aws.String(versions[i].VersionID),
+			})
+			require.NoError(t, err)
+			require.Equal(t, versions[i+1].VersionID, version)
+		})
+	}
+
+	for _, version := range []string{"v9", "moo-cow"} {
+		t.Run("error "+version, func(t *testing.T) {
+			prev, err := mockStorage.Previous(context.Background(), storage.Key{
+				Bucket:  "somebucket",
+				Object:  "123/0.crl",
+				Version: aws.String(version),
+			})
+			require.Error(t, err)
+			require.Equal(t, "", prev)
+		})
+	}
+
+	_, err := mockStorage.Previous(context.Background(), storage.Key{
+		Bucket:  "somebucket",
+		Object:  "123/0.crl",
+		Version: aws.String("v9"),
+	})
+	require.ErrorIs(t, err, storage.ErrNoPreviousVersion)
+}
+
+func TestListPaginated(t *testing.T) {
+	data := make(map[string][]mock.MockObject)
+	var expected []storage.Object
+	for i := range 7 {
+		key := fmt.Sprintf("123/%d.crl", i)
+		modified := time.Date(2026, 6, 1, i, 0, 0, 0, time.UTC)
+		data[key] = []mock.MockObject{{VersionID: "new", LastModified: modified}, {VersionID: "old"}}
+		expected = append(expected, storage.Object{Key: key, LastModified: modified})
+	}
+	data["456/0.crl"] = []mock.MockObject{{VersionID: "other"}}
+
+	mockStorage := mock.NewPaginated(t, "somebucket", 3, data)
+
+	objects, err := mockStorage.List(context.Background(), "somebucket", "123/")
+	require.NoError(t, err)
+	require.Equal(t, expected, objects)
+
+	objects, err = mockStorage.List(context.Background(), "somebucket", "789/")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+}
+
+func TestUploaded(t *testing.T) {
+	newer := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	older := time.Date(2026, 6, 1, 6, 0, 0, 0, time.UTC)
+	mockStorage := mock.New(t, "somebucket", map[string][]mock.MockObject{
+		"123/0.crl": {
+			{VersionID: "222", LastModified: newer},
+			{VersionID: "111", LastModified: older},
+		},
+	})
+
+	uploaded, err := mockStorage.Uploaded(context.Background(), storage.Key{Bucket: "somebucket", Object: "123/0.crl"})
+	require.NoError(t, err)
+	require.Equal(t, newer, uploaded)
+
+	uploaded, err = mockStorage.Uploaded(context.Background(), storage.Key{Bucket: "somebucket", Object: "123/0.crl", Version: aws.String("111")})
+	require.NoError(t, err)
+	require.Equal(t, older, uploaded)
+
+	_, err = mockStorage.Uploaded(context.Background(), storage.Key{Bucket: "somebucket", Object: "123/0.crl", Version: aws.String("moo-cow")})
+	require.Error(t, err)
+}